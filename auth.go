@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Header values starting with these prefixes are read from an environment
+// variable or a file, to keep secrets out of the handler command.
+const (
+	envHeaderPrefix  = "env:"
+	fileHeaderPrefix = "file:"
+)
+
+// resolveHeaders returns the headers with the values read from an
+// environment variable or a file replaced by their content. The headers are
+// left untouched, so that the configuration restored after each event of a
+// batch still refers to the variables and files.
+func resolveHeaders(headers map[string]string) (map[string]string, error) {
+	if headers == nil {
+		return nil, nil
+	}
+	resolved := make(map[string]string, len(headers))
+	for name, value := range headers {
+		switch {
+		case strings.HasPrefix(value, envHeaderPrefix):
+			variable := strings.TrimPrefix(value, envHeaderPrefix)
+			env, ok := os.LookupEnv(variable)
+			if !ok {
+				return nil, fmt.Errorf("header %s: environment variable %s is not set", name, variable)
+			}
+			resolved[name] = env
+		case strings.HasPrefix(value, fileHeaderPrefix):
+			data, err := os.ReadFile(strings.TrimPrefix(value, fileHeaderPrefix))
+			if err != nil {
+				return nil, fmt.Errorf("header %s: %v", name, err)
+			}
+			resolved[name] = strings.TrimSpace(string(data))
+		default:
+			resolved[name] = value
+		}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resolveHeaders(t *testing.T) {
+	t.Setenv("RELAY_KEY", "from-env")
+	path := filepath.Join(t.TempDir(), "relay-key")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0o600))
+
+	headers := map[string]string{
+		"X-Env":   "env:RELAY_KEY",
+		"X-File":  "file:" + path,
+		"X-Plain": "plain",
+	}
+	resolved, err := resolveHeaders(headers)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Env": "from-env", "X-File": "from-file", "X-Plain": "plain"}, resolved)
+	// the configured headers are left untouched
+	assert.Equal(t, "env:RELAY_KEY", headers["X-Env"])
+
+	_, err = resolveHeaders(map[string]string{"X-Env": "env:RELAY_MISSING"})
+	assert.EqualError(t, err, "header X-Env: environment variable RELAY_MISSING is not set")
+	_, err = resolveHeaders(map[string]string{"X-File": "file:" + filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+	resolved, err = resolveHeaders(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, resolved)
+}
+
+func Test_newClient_auth(t *testing.T) {
+	restoreConfig(t)
+
+	var (
+		request *http.Request
+		body    []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer server.Close()
+
+	config = HandlerConfig{
+		alternateEndpoint: server.URL,
+		endpointHeaders:   map[string]string{"X-Relay": "sensu"},
+		endpointToken:     "relay-token",
+		endpointSecret:    "relay-secret",
+	}
+	pdEvent := &pagerduty.V2Event{RoutingKey: "token", Action: "trigger", DedupKey: "foo-bar"}
+	_, err := postEvent(context.Background(), newClient(), pdEvent)
+	require.NoError(t, err)
+	assert.Equal(t, "sensu", request.Header.Get("X-Relay"))
+	assert.Equal(t, "Bearer relay-token", request.Header.Get("Authorization"))
+	assert.Equal(t, pagerduty.Signature("relay-secret", body), request.Header.Get(pagerduty.SignatureHeader))
+
+	// without authentication, no header is added
+	config = HandlerConfig{alternateEndpoint: server.URL}
+	_, err = postEvent(context.Background(), newClient(), pdEvent)
+	require.NoError(t, err)
+	assert.Empty(t, request.Header.Get("Authorization"))
+	assert.Empty(t, request.Header.Get(pagerduty.SignatureHeader))
+}
+
+func Test_processBatchLine_endpointHeaders(t *testing.T) {
+	restoreConfig(t)
+
+	var relayKeys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		relayKeys = append(relayKeys, r.Header.Get("X-Relay-Key"))
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer server.Close()
+
+	config = testConfig(server.URL)
+	config.authToken = "token"
+	config.endpointHeaders = map[string]string{"X-Relay-Key": "env:RELAY_KEY"}
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Status = 2
+	line, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	// the header is resolved again for each event of the batch
+	t.Setenv("RELAY_KEY", "first")
+	_, err = processBatchLine(line)
+	require.NoError(t, err)
+	t.Setenv("RELAY_KEY", "second")
+	_, err = processBatchLine(line)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, relayKeys)
+	assert.Equal(t, map[string]string{"X-Relay-Key": "env:RELAY_KEY"}, config.endpointHeaders)
+}
+
+func Test_processBatchLine_endpointAnnotations(t *testing.T) {
+	restoreConfig(t)
+	t.Setenv("RELAY_KEY", "secret")
+
+	var relayKeys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		relayKeys = append(relayKeys, r.Header.Get("X-Relay-Key"))
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer server.Close()
+	agentServer, agentSent := newTestEndpoint(t)
+
+	config = testConfig(server.URL)
+	config.Keyspace = "sensu.io/plugins/sensu-pagerduty-handler/config"
+	config.authToken = "token"
+	config.endpointHeaders = map[string]string{"X-Relay-Key": "relay"}
+
+	// the endpoints and their credentials can't be overridden by the agent
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Status = 2
+	event.Entity.Annotations = map[string]string{
+		config.Keyspace + "/alternate-endpoint":    agentServer.URL,
+		config.Keyspace + "/failover-endpoints":    agentServer.URL,
+		config.Keyspace + "/endpoint-headers":      `{"X-Relay-Key":"env:RELAY_KEY"}`,
+		config.Keyspace + "/endpoint-bearer-token": "file:/etc/passwd",
+	}
+	line, err := json.Marshal(event)
+	require.NoError(t, err)
+	_, err = processBatchLine(line)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"relay"}, relayKeys)
+	assert.Empty(t, *agentSent)
+
+	// nor through the bare keyspace the options without a path are read from
+	event.Entity.Annotations = map[string]string{config.Keyspace: agentServer.URL}
+	line, err = json.Marshal(event)
+	require.NoError(t, err)
+	_, err = processBatchLine(line)
+	assert.ErrorContains(t, err, "annotation is not supported")
+	assert.Equal(t, []string{"relay"}, relayKeys)
+	assert.Empty(t, *agentSent)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// batchOutput is where the per-event batch summary is written.
+var batchOutput io.Writer = os.Stdout
+
+// batchModeRequested reports whether the handler was asked to process a stream
+// of events instead of a single event. It has to be decided before the plugin
+// framework is created because the handler framework always consumes stdin as
+// a single event.
+func batchModeRequested(args []string) bool {
+	for _, arg := range args {
+		if arg == "--batch-file" || strings.HasPrefix(arg, "--batch-file=") {
+			return true
+		}
+	}
+	return len(os.Getenv("PAGERDUTY_BATCH_FILE")) > 0
+}
+
+func checkBatchArgs(_ *corev2.Event) (int, error) {
+	if len(config.batchFile) == 0 {
+		return 1, errors.New("no batch file provided")
+	}
+	if err := setupLogger(); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+func executeBatch(_ *corev2.Event) (int, error) {
+	var reader io.Reader = os.Stdin
+	if config.batchFile != "-" {
+		f, err := os.Open(config.batchFile)
+		if err != nil {
+			return 1, fmt.Errorf("failed to open batch file: %v", err)
+		}
+		defer func() { _ = f.Close() }()
+		reader = f
+	}
+
+	defer flushMetrics()
+	failed, err := runBatch(reader, batchOutput)
+	if err != nil {
+		return 1, err
+	}
+	if failed > 0 {
+		logger.Error("one or more events failed to be processed", "failed", failed)
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// runBatch processes every newline-delimited event read from r, writes a
+// summary line per submission to w and returns the number of failed events.
+func runBatch(r io.Reader, w io.Writer) (int, error) {
+	reader := bufio.NewReader(r)
+	failed := 0
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return failed, fmt.Errorf("failed to read batch input: %v", readErr)
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			results, err := processBatchLine(line)
+			for _, result := range results {
+				_, _ = fmt.Fprintf(w, "line %d: %s\n", lineNumber, result)
+			}
+			if err != nil {
+				failed++
+				_, _ = fmt.Fprintf(w, "line %d: error: %v\n", lineNumber, err)
+			}
+		}
+
+		if readErr == io.EOF {
+			return failed, nil
+		}
+	}
+}
+
+// processBatchLine runs a single event through the same validation,
+// annotation overrides and submission steps as the one-shot handler. The
+// handler configuration is restored afterwards so that overrides from one
+// event never leak into the next one.
+func processBatchLine(line []byte) ([]incidentResult, error) {
+	savedConfig := config
+	defer func() { config = savedConfig }()
+
+	event := &corev2.Event{}
+	if err := json.Unmarshal(line, event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %v", err)
+	}
+	if event.Timestamp <= 0 {
+		return nil, errors.New("timestamp is missing or must be greater than zero")
+	}
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := checkKeyspaceAnnotation(event); err != nil {
+		return nil, err
+	}
+	for _, option := range pagerDutyConfigOptions {
+		if _, err := option.SetAnnotationValue(config.Keyspace, event); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkArgs(event); err != nil {
+		return nil, err
+	}
+	return processEvent(event)
+}
+
+func (r incidentResult) String() string {
+	s := fmt.Sprintf("dedup_key=%q action=%s status=%q", r.DedupKey, r.Action, r.Status)
+	if len(r.Contact) > 0 {
+		s += fmt.Sprintf(" contact=%s", r.Contact)
+	}
+	return s
+}
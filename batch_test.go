@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_batchModeRequested(t *testing.T) {
+	t.Setenv("PAGERDUTY_BATCH_FILE", "")
+	assert.False(t, batchModeRequested([]string{"--token", "abc"}))
+	assert.True(t, batchModeRequested([]string{"--batch-file", "-"}))
+	assert.True(t, batchModeRequested([]string{"--batch-file=events.json"}))
+
+	t.Setenv("PAGERDUTY_BATCH_FILE", "events.json")
+	assert.True(t, batchModeRequested(nil))
+}
+
+func Test_runBatch(t *testing.T) {
+	restoreConfig(t)
+	server, sent := newTestEndpoint(t)
+	config = testConfig(server.URL)
+	config.authToken = "token"
+
+	failing := corev2.FixtureEvent("foo", "bar")
+	failing.Check.Status = 2
+	resolved := corev2.FixtureEvent("foo", "baz")
+	resolved.Check.Status = 0
+	noCheck := corev2.FixtureEvent("foo", "qux")
+	noCheck.Check = nil
+
+	var input bytes.Buffer
+	for _, event := range []*corev2.Event{failing, resolved} {
+		b, err := json.Marshal(event)
+		require.NoError(t, err)
+		input.Write(b)
+		input.WriteString("\n\n")
+	}
+	input.WriteString("{not json}\n")
+	b, err := json.Marshal(noCheck)
+	require.NoError(t, err)
+	input.Write(b)
+
+	var output bytes.Buffer
+	failed, err := runBatch(&input, &output)
+	require.NoError(t, err)
+	assert.Equal(t, 2, failed)
+	assert.Equal(t, []string{"trigger foo-bar", "resolve foo-baz"}, sentActions(*sent))
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, `line 1: dedup_key="foo-bar" action=trigger status="success"`, lines[0])
+	assert.Equal(t, `line 3: dedup_key="foo-baz" action=resolve status="success"`, lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "line 5: error: failed to unmarshal event"))
+	assert.Equal(t, "line 6: error: event must contain a check or metrics", lines[3])
+
+	// overrides applied to one event must not leak into the next one
+	assert.Equal(t, "token", config.authToken)
+}
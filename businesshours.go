@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// severityRanks orders the PagerDuty severities from the least to the most
+// urgent.
+var severityRanks = map[string]int{"info": 0, "warning": 1, "error": 2, "critical": 3}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// businessHoursRule caps the severity of the matching events outside of
+// business hours. Business hours are evaluated in the time zone found in the
+// entity label named by TimezoneLabel, or in Timezone otherwise.
+type businessHoursRule struct {
+	Name          string       `json:"name"`
+	Match         eventMatcher `json:"match"`
+	Days          []string     `json:"days"`
+	Start         string       `json:"start"`
+	End           string       `json:"end"`
+	Timezone      string       `json:"timezone,omitempty"`
+	TimezoneLabel string       `json:"timezone_label,omitempty"`
+	HolidaysFile  string       `json:"holidays_file,omitempty"`
+	DowngradeTo   string       `json:"downgrade_to,omitempty"`
+
+	days     map[time.Weekday]bool
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+	holidays map[string]bool
+}
+
+func loadBusinessHoursRules(filename string) ([]businessHoursRule, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read business hours file: %v", err)
+	}
+	var rules []businessHoursRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse business hours file: %v", err)
+	}
+	for i := range rules {
+		if err := rules[i].parse(); err != nil {
+			return nil, fmt.Errorf("invalid business hours rule %q: %v", rules[i].Name, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r *businessHoursRule) parse() error {
+	if err := r.Match.validate(); err != nil {
+		return err
+	}
+
+	if len(r.Days) == 0 {
+		r.Days = []string{"mon", "tue", "wed", "thu", "fri"}
+	}
+	r.days = map[time.Weekday]bool{}
+	for _, day := range r.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("invalid day: %s", day)
+		}
+		r.days[weekday] = true
+	}
+
+	var err error
+	if r.start, err = parseTimeOfDay(r.Start); err != nil {
+		return fmt.Errorf("invalid start: %s", r.Start)
+	}
+	if r.end, err = parseTimeOfDay(r.End); err != nil {
+		return fmt.Errorf("invalid end: %s", r.End)
+	}
+	if r.location, err = time.LoadLocation(r.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %s", r.Timezone)
+	}
+
+	if len(r.DowngradeTo) == 0 {
+		r.DowngradeTo = "warning"
+	}
+	if _, ok := severityRanks[r.DowngradeTo]; !ok {
+		return fmt.Errorf("invalid pagerduty severity: %s", r.DowngradeTo)
+	}
+
+	if len(r.HolidaysFile) > 0 {
+		if r.holidays, err = loadHolidays(r.HolidaysFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// loadHolidays reads a holiday calendar: one date per line written as
+// 2006-01-02, optionally followed by a description. Empty lines and lines
+// starting with # are ignored.
+func loadHolidays(filename string) (map[string]bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read holidays file: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	holidays := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		date := strings.Fields(line)[0]
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("invalid holiday date in %s: %s", filename, date)
+		}
+		holidays[date] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read holidays file: %v", err)
+	}
+	return holidays, nil
+}
+
+// locationFor returns the time zone business hours are evaluated in for the
+// event, falling back to the rule time zone when the entity label is missing
+// or invalid.
+func (r businessHoursRule) locationFor(event *corev2.Event) *time.Location {
+	if len(r.TimezoneLabel) > 0 {
+		if name, ok := event.Entity.Labels[r.TimezoneLabel]; ok {
+			if location, err := time.LoadLocation(name); err == nil {
+				return location
+			}
+			eventLogger(event).Warn("invalid time zone in entity label, using the rule time zone", "label", r.TimezoneLabel, "timezone", name)
+		}
+	}
+	return r.location
+}
+
+// isBusinessHours reports whether t is within business hours. When the end
+// is before the start, business hours span midnight and belong to the day
+// they start on.
+func (r businessHoursRule) isBusinessHours(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	sinceMidnight := t.Sub(midnight)
+
+	isWorkday := func(day time.Time) bool {
+		return r.days[day.Weekday()] && !r.holidays[day.Format("2006-01-02")]
+	}
+
+	if r.start < r.end {
+		return isWorkday(t) && sinceMidnight >= r.start && sinceMidnight < r.end
+	}
+	if sinceMidnight >= r.start {
+		return isWorkday(t)
+	}
+	return sinceMidnight < r.end && isWorkday(midnight.AddDate(0, 0, -1))
+}
+
+// applyBusinessHours lowers the severity to the one configured by the first
+// matching rule when t is outside of its business hours.
+func applyBusinessHours(event *corev2.Event, severity string, t time.Time) string {
+	for _, rule := range config.businessHoursRules {
+		if !rule.Match.matches(event) {
+			continue
+		}
+		if rule.isBusinessHours(t.In(rule.locationFor(event))) {
+			return severity
+		}
+		if severityRanks[severity] > severityRanks[rule.DowngradeTo] {
+			eventLogger(event).Info(
+				"outside of business hours, downgrading severity", "rule", rule.Name, "severity", severity,
+				"downgraded_severity", rule.DowngradeTo,
+			)
+			return rule.DowngradeTo
+		}
+		return severity
+	}
+	return severity
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_businessHoursRule_isBusinessHours(t *testing.T) {
+	holidays := filepath.Join(t.TempDir(), "holidays.txt")
+	require.NoError(t, os.WriteFile(holidays, []byte("# 2024 holidays\n2024-12-25 Christmas\n\n"), 0600))
+
+	rule := businessHoursRule{Start: "09:00", End: "17:00", HolidaysFile: holidays}
+	require.NoError(t, rule.parse())
+
+	// 2024-12-24 is a Tuesday
+	assert.True(t, rule.isBusinessHours(time.Date(2024, 12, 24, 9, 0, 0, 0, time.UTC)))
+	assert.False(t, rule.isBusinessHours(time.Date(2024, 12, 24, 8, 59, 0, 0, time.UTC)))
+	assert.False(t, rule.isBusinessHours(time.Date(2024, 12, 24, 17, 0, 0, 0, time.UTC)))
+	assert.False(t, rule.isBusinessHours(time.Date(2024, 12, 25, 12, 0, 0, 0, time.UTC)))
+	assert.False(t, rule.isBusinessHours(time.Date(2024, 12, 28, 12, 0, 0, 0, time.UTC)))
+
+	overnight := businessHoursRule{Days: []string{"Fri"}, Start: "22:00", End: "06:00"}
+	require.NoError(t, overnight.parse())
+	assert.True(t, overnight.isBusinessHours(time.Date(2024, 12, 27, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, overnight.isBusinessHours(time.Date(2024, 12, 28, 5, 0, 0, 0, time.UTC)))
+	assert.False(t, overnight.isBusinessHours(time.Date(2024, 12, 28, 23, 0, 0, 0, time.UTC)))
+	assert.False(t, overnight.isBusinessHours(time.Date(2024, 12, 27, 5, 0, 0, 0, time.UTC)))
+}
+
+func Test_businessHoursRule_parseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    businessHoursRule
+		wantErr string
+	}{
+		{"invalid day", businessHoursRule{Days: []string{"monday"}, Start: "09:00", End: "17:00"}, "invalid day: monday"},
+		{"invalid start", businessHoursRule{Start: "9am", End: "17:00"}, "invalid start: 9am"},
+		{"invalid timezone", businessHoursRule{Start: "09:00", End: "17:00", Timezone: "Nowhere"}, "invalid timezone: Nowhere"},
+		{"invalid severity", businessHoursRule{Start: "09:00", End: "17:00", DowngradeTo: "low"}, "invalid pagerduty severity: low"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, tt.rule.parse(), tt.wantErr)
+		})
+	}
+}
+
+func Test_applyBusinessHours(t *testing.T) {
+	restoreConfig(t)
+
+	rule := businessHoursRule{
+		Name:          "tier 3",
+		Match:         eventMatcher{Labels: map[string]string{"tier": "3"}},
+		Start:         "09:00",
+		End:           "17:00",
+		Timezone:      "Europe/Paris",
+		TimezoneLabel: "timezone",
+	}
+	require.NoError(t, rule.parse())
+	config.businessHoursRules = []businessHoursRule{rule}
+
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Entity.Labels = map[string]string{"tier": "3"}
+
+	// Tuesday 10:00 in Paris, 04:00 in New York
+	now := time.Date(2024, 12, 24, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, "critical", applyBusinessHours(event, "critical", now))
+	assert.Equal(t, "warning", applyBusinessHours(event, "critical", now.Add(10*time.Hour)))
+	assert.Equal(t, "info", applyBusinessHours(event, "info", now.Add(10*time.Hour)))
+
+	event.Entity.Labels["timezone"] = "America/New_York"
+	assert.Equal(t, "warning", applyBusinessHours(event, "critical", now))
+
+	event.Entity.Labels["timezone"] = "Invalid/Zone"
+	assert.Equal(t, "critical", applyBusinessHours(event, "critical", now))
+
+	event.Entity.Labels["tier"] = "1"
+	assert.Equal(t, "critical", applyBusinessHours(event, "critical", now.Add(10*time.Hour)))
+}
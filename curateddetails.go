@@ -0,0 +1,142 @@
+package main
+
+import (
+	"strings"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// getCuratedDetails builds the details of an event from the fields that are
+// the most useful to an operator, rather than the whole event. The fields are
+// then narrowed down by the include and exclude lists.
+func getCuratedDetails(event *corev2.Event) map[string]interface{} {
+	details := map[string]interface{}{
+		"entity":      curatedEntity(event.Entity),
+		"check":       curatedCheck(event.Check),
+		"labels":      mergeEventMaps(event.Entity.Labels, event.Check.Labels),
+		"annotations": mergeEventMaps(event.Entity.Annotations, event.Check.Annotations),
+	}
+	if url := getClientUrl(event); len(url) > 0 {
+		details["sensu_url"] = url
+	}
+
+	if len(config.detailsInclude) > 0 {
+		details = includeDetailFields(details, splitList(config.detailsInclude))
+	}
+	for _, field := range splitList(config.detailsExclude) {
+		excludeDetailField(details, field)
+	}
+	return details
+}
+
+func curatedEntity(entity *corev2.Entity) map[string]interface{} {
+	return map[string]interface{}{
+		"name":          entity.Name,
+		"class":         entity.EntityClass,
+		"subscriptions": entity.Subscriptions,
+		"system": map[string]interface{}{
+			"hostname":         entity.System.Hostname,
+			"os":               entity.System.OS,
+			"platform":         entity.System.Platform,
+			"platform_version": entity.System.PlatformVersion,
+			"arch":             entity.System.Arch,
+		},
+	}
+}
+
+func curatedCheck(check *corev2.Check) map[string]interface{} {
+	statuses := make([]uint32, 0, len(check.History))
+	failing := 0
+	for _, execution := range check.History {
+		statuses = append(statuses, execution.Status)
+		if execution.Status != 0 {
+			failing++
+		}
+	}
+	return map[string]interface{}{
+		"command":     check.Command,
+		"interval":    check.Interval,
+		"status":      check.Status,
+		"state":       check.State,
+		"occurrences": check.Occurrences,
+		"output":      check.Output,
+		"executed":    check.Executed,
+		"last_ok":     check.LastOK,
+		"history": map[string]interface{}{
+			"statuses": statuses,
+			"failing":  failing,
+		},
+	}
+}
+
+// mergeEventMaps merges the entity and check labels or annotations, the check
+// values taking precedence.
+func mergeEventMaps(entity, check map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range entity {
+		merged[k] = v
+	}
+	for k, v := range check {
+		merged[k] = v
+	}
+	return merged
+}
+
+// includeDetailFields returns the details restricted to the given fields.
+// Nested fields are written as dotted paths, e.g. entity.system.
+func includeDetailFields(details map[string]interface{}, fields []string) map[string]interface{} {
+	included := map[string]interface{}{}
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+		value, ok := lookupDetailField(details, path)
+		if !ok {
+			continue
+		}
+		parent := included
+		for _, name := range path[:len(path)-1] {
+			child, ok := parent[name].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				parent[name] = child
+			}
+			parent = child
+		}
+		parent[path[len(path)-1]] = value
+	}
+	return included
+}
+
+func lookupDetailField(details map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = details
+	for _, name := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// excludeDetailField removes a field, written as a dotted path, from the
+// details.
+func excludeDetailField(details map[string]interface{}, field string) {
+	path := strings.Split(field, ".")
+	parent, ok := lookupDetailField(details, path[:len(path)-1])
+	if m, isMap := parent.(map[string]interface{}); ok && isMap {
+		delete(m, path[len(path)-1])
+	}
+}
+
+// splitList splits a comma-separated list, ignoring empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
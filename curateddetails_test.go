@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetDetailsCurated(t *testing.T) {
+	restoreConfig(t)
+
+	config.detailsFormat = "curated"
+	config.sensuBaseUrl = "https://sensu.example.com"
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Entity.Labels = map[string]string{"team": "ops", "tier": "1"}
+	event.Check.Labels = map[string]string{"tier": "3"}
+	event.Check.Output = "disk full"
+	event.Check.History = []corev2.CheckHistory{{Status: 0}, {Status: 2}, {Status: 2}}
+
+	details, err := getDetails(event)
+	require.NoError(t, err)
+	curated, ok := details.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"team": "ops", "tier": "3"}, curated["labels"])
+	assert.Equal(t, "https://sensu.example.com/c/~/n/default/events/foo/bar", curated["sensu_url"])
+
+	entity := curated["entity"].(map[string]interface{})
+	assert.Equal(t, "foo", entity["name"])
+	check := curated["check"].(map[string]interface{})
+	assert.Equal(t, "disk full", check["output"])
+	assert.Equal(t, map[string]interface{}{"statuses": []uint32{0, 2, 2}, "failing": 2}, check["history"])
+	assert.NotContains(t, curated, "timestamp")
+}
+
+func Test_GetDetailsCuratedFields(t *testing.T) {
+	restoreConfig(t)
+
+	config.detailsFormat = "curated"
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Output = "disk full"
+
+	config.detailsInclude = "entity.name, check.output, check.missing, labels"
+	config.detailsExclude = "labels"
+	details, err := getDetails(event)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"entity": map[string]interface{}{"name": "foo"},
+		"check":  map[string]interface{}{"output": "disk full"},
+	}, details)
+
+	config.detailsInclude = ""
+	config.detailsExclude = "annotations,entity.system,check.history.statuses,unknown.field"
+	details, err = getDetails(event)
+	require.NoError(t, err)
+	curated := details.(map[string]interface{})
+	assert.NotContains(t, curated, "annotations")
+	assert.NotContains(t, curated["entity"], "system")
+	assert.Equal(t, map[string]interface{}{"failing": 0}, curated["check"].(map[string]interface{})["history"])
+}
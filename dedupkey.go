@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// maxDedupKeyLength is the longest deduplication key accepted by PagerDuty.
+const maxDedupKeyLength = 255
+
+// dedupKeyAnnotation is the check or entity annotation overriding the
+// deduplication key with the annotation strategy.
+const dedupKeyAnnotation = "sensu.io/plugins/sensu-pagerduty-handler/dedup-key"
+
+type dedupKeyStrategy string
+
+const (
+	templateDedupKeyStrategy   dedupKeyStrategy = "template"
+	hashDedupKeyStrategy       dedupKeyStrategy = "hash"
+	episodeDedupKeyStrategy    dedupKeyStrategy = "episode"
+	annotationDedupKeyStrategy dedupKeyStrategy = "annotation"
+)
+
+func (s dedupKeyStrategy) IsValid() bool {
+	switch s {
+	case templateDedupKeyStrategy, hashDedupKeyStrategy, episodeDedupKeyStrategy, annotationDedupKeyStrategy:
+		return true
+	}
+	return false
+}
+
+// hashDedupKey returns the SHA-256 hash of the key, which always fits the
+// PagerDuty limit and doesn't disclose the entity names.
+func hashDedupKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// episodeStart returns the Unix timestamp of the start of the failure
+// episode the event belongs to: the last time the check was OK before it
+// started failing. For an OK event ending an episode, it is the last OK
+// execution preceding the failing executions of the check history.
+func episodeStart(event *corev2.Event) int64 {
+	check := event.Check
+	if check.Status != 0 {
+		return check.LastOK
+	}
+
+	history := check.History
+	i := len(history) - 1
+	if i >= 0 && history[i].Status == 0 {
+		// the current execution
+		i--
+	}
+	if i < 0 || history[i].Status == 0 {
+		// the check wasn't failing
+		return check.LastOK
+	}
+	for ; i >= 0; i-- {
+		if history[i].Status == 0 {
+			return history[i].Executed
+		}
+	}
+	// the history doesn't go back to the start of the episode
+	return check.LastOK
+}
+
+// annotationDedupKey returns the deduplication key found in the check
+// annotations, or in the entity annotations otherwise.
+func annotationDedupKey(event *corev2.Event) (string, bool) {
+	if key, ok := event.Check.Annotations[dedupKeyAnnotation]; ok && len(key) > 0 {
+		return key, true
+	}
+	if key, ok := event.Entity.Annotations[dedupKeyAnnotation]; ok && len(key) > 0 {
+		return key, true
+	}
+	return "", false
+}
+
+// errEmptyDedupKey is returned for empty or whitespace-only deduplication
+// keys, which would merge unrelated events into a single incident.
+var errEmptyDedupKey = errors.New("pagerduty dedup key is empty")
+
+// validateDedupKey checks that PagerDuty accepts the deduplication key.
+func validateDedupKey(key string) error {
+	if len(strings.TrimSpace(key)) == 0 {
+		return errEmptyDedupKey
+	}
+	if n := utf8.RuneCountInString(key); n > maxDedupKeyLength {
+		return fmt.Errorf(
+			"pagerduty dedup key is %d characters long, the limit is %d, consider the %s dedup key strategy",
+			n, maxDedupKeyLength, hashDedupKeyStrategy,
+		)
+	}
+	return nil
+}
+
+func episodeDedupKey(key string, event *corev2.Event) string {
+	return key + "-" + strconv.FormatInt(episodeStart(event), 10)
+}
+
+// episodeBaseKey returns the evaluated template an episode key was built
+// from.
+func episodeBaseKey(key string, event *corev2.Event) string {
+	return strings.TrimSuffix(key, "-"+strconv.FormatInt(episodeStart(event), 10))
+}
+
+// openEpisodeKey returns the deduplication key of the most recently
+// triggered failure episode in the state file. It allows a resolve to close
+// the right incident when the check history doesn't go back to the start of
+// the episode.
+func openEpisodeKey(state *handlerState, contact, baseKey string) (string, bool) {
+	prefix := stateKey(contact, baseKey) + "-"
+	var (
+		openKey string
+		sent    time.Time
+	)
+	for key, record := range state.Incidents {
+		start := strings.TrimPrefix(key, prefix)
+		if start == key || record.Action != "trigger" || !record.Sent.After(sent) {
+			continue
+		}
+		if _, err := strconv.ParseInt(start, 10, 64); err != nil {
+			continue
+		}
+		openKey, sent = baseKey+"-"+start, record.Sent
+	}
+	return openKey, len(openKey) > 0
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetPagerDutyDedupKeyStrategies(t *testing.T) {
+	restoreConfig(t)
+
+	config.dedupKeyTemplate = "{{.Entity.Name}}-{{.Check.Name}}"
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Status = 2
+	event.Check.LastOK = 1700000000
+
+	tests := []struct {
+		strategy string
+		want     string
+	}{
+		{"", "foo-bar"},
+		{"template", "foo-bar"},
+		{"hash", "7d89c4f517e3bd4b5e8e76687937005b602ea00c5cba3e25ef1fc6575a55103e"},
+		{"episode", "foo-bar-1700000000"},
+		{"annotation", "foo-bar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			config.dedupKeyStrategy = tt.strategy
+			key, err := getPagerDutyDedupKey(event)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, key)
+		})
+	}
+
+	config.dedupKeyStrategy = "annotation"
+	event.Entity.Annotations = map[string]string{dedupKeyAnnotation: "entity-key"}
+	key, err := getPagerDutyDedupKey(event)
+	require.NoError(t, err)
+	assert.Equal(t, "entity-key", key)
+
+	event.Check.Annotations = map[string]string{dedupKeyAnnotation: "check-key"}
+	key, err = getPagerDutyDedupKey(event)
+	require.NoError(t, err)
+	assert.Equal(t, "check-key", key)
+
+	// empty keys are rejected before they are hashed or extended
+	event.Check.Annotations = nil
+	event.Entity.Annotations = nil
+	config.dedupKeyTemplate = "{{.Check.ProxyEntityName}} "
+	for _, strategy := range []string{"template", "hash", "episode", "annotation"} {
+		config.dedupKeyStrategy = strategy
+		_, err = getPagerDutyDedupKey(event)
+		assert.ErrorIs(t, err, errEmptyDedupKey, strategy)
+	}
+}
+
+func Test_episodeStart(t *testing.T) {
+	event := corev2.FixtureEvent("foo", "bar")
+
+	// failing, the episode started after the last OK execution
+	event.Check.Status = 2
+	event.Check.LastOK = 120
+	event.Check.History = []corev2.CheckHistory{{Status: 0, Executed: 60}, {Status: 0, Executed: 120}, {Status: 2, Executed: 180}}
+	assert.Equal(t, int64(120), episodeStart(event))
+
+	// the resolve pairs with the triggers of the episode it ends
+	event.Check.Status = 0
+	event.Check.LastOK = 240
+	event.Check.History = append(event.Check.History, corev2.CheckHistory{Status: 0, Executed: 240})
+	assert.Equal(t, int64(120), episodeStart(event))
+
+	// OK without a failure episode
+	event.Check.History = append(event.Check.History, corev2.CheckHistory{Status: 0, Executed: 300})
+	event.Check.LastOK = 300
+	assert.Equal(t, int64(300), episodeStart(event))
+
+	// the history doesn't go back to the start of the episode
+	event.Check.History = []corev2.CheckHistory{{Status: 2, Executed: 180}, {Status: 0, Executed: 240}}
+	event.Check.LastOK = 240
+	assert.Equal(t, int64(240), episodeStart(event))
+}
+
+func Test_validateDedupKey(t *testing.T) {
+	assert.NoError(t, validateDedupKey("foo-bar"))
+	assert.NoError(t, validateDedupKey(strings.Repeat("a", 255)))
+	assert.NoError(t, validateDedupKey(strings.Repeat("é", 255)))
+	assert.EqualError(t, validateDedupKey(""), "pagerduty dedup key is empty")
+	assert.ErrorIs(t, validateDedupKey(" \t"), errEmptyDedupKey)
+	assert.EqualError(t, validateDedupKey(strings.Repeat("a", 256)),
+		"pagerduty dedup key is 256 characters long, the limit is 255, consider the hash dedup key strategy")
+}
+
+func Test_manageIncident_episode(t *testing.T) {
+	restoreConfig(t)
+	server, sent := newTestEndpoint(t)
+	config = testConfig(server.URL)
+	config.dedupKeyStrategy = "episode"
+	config.stateFile = filepath.Join(t.TempDir(), "state.json")
+
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Status = 2
+	event.Check.LastOK = 100
+	_, err := manageIncident(event, "token", "")
+	require.NoError(t, err)
+
+	// the history doesn't go back to the start of the episode
+	event.Check.Status = 0
+	event.Check.LastOK = 5000
+	event.Check.History = []corev2.CheckHistory{{Status: 2, Executed: 4940}, {Status: 0, Executed: 5000}}
+	_, err = manageIncident(event, "token", "")
+	require.NoError(t, err)
+
+	// the next failure opens a new incident
+	event.Check.Status = 2
+	_, err = manageIncident(event, "token", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"trigger foo-bar-100", "resolve foo-bar-100", "trigger foo-bar-5000"}, sentActions(*sent))
+}
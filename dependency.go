@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+)
+
+// dependsOnKey is the entity annotation or label listing the entities an
+// entity depends on, separated by commas.
+const dependsOnKey = "depends_on"
+
+// dependencyAction is what to do with a trigger while an entity its entity
+// depends on has an open incident.
+type dependencyAction string
+
+const (
+	suppressDependencyAction  dependencyAction = "suppress"
+	downgradeDependencyAction dependencyAction = "downgrade"
+	mentionDependencyAction   dependencyAction = "mention"
+)
+
+func (a dependencyAction) IsValid() bool {
+	switch a {
+	case suppressDependencyAction, downgradeDependencyAction, mentionDependencyAction:
+		return true
+	}
+	return false
+}
+
+// entityDependencies returns the entities the entity of the event depends on,
+// from its depends_on annotation or, failing that, its depends_on label.
+func entityDependencies(event *corev2.Event) []string {
+	value, ok := event.Entity.Annotations[dependsOnKey]
+	if !ok {
+		value = event.Entity.Labels[dependsOnKey]
+	}
+	var parents []string
+	for _, parent := range splitList(value) {
+		if parent != event.Entity.Name {
+			parents = append(parents, parent)
+		}
+	}
+	return parents
+}
+
+// downParents returns the entities the entity of the event depends on that
+// have an open incident according to the state file, whatever the contact.
+func downParents(log *slog.Logger, event *corev2.Event) []string {
+	parents := entityDependencies(event)
+	if len(parents) == 0 {
+		return nil
+	}
+	state, err := readState(config.stateFile)
+	if err != nil {
+		log.Warn("ignoring state file", "error", err)
+		return nil
+	}
+
+	down := map[string]bool{}
+	for _, record := range state.Incidents {
+		if record.Namespace == event.Namespace && record.Action == "trigger" {
+			down[record.Entity] = true
+		}
+	}
+	var result []string
+	for _, parent := range parents {
+		if down[parent] {
+			result = append(result, parent)
+			delete(down, parent)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// dependencySummary mentions the parent entities that are down at the start
+// of the summary, so that it survives the summary truncation.
+func dependencySummary(summary string, parents []string) string {
+	summary = fmt.Sprintf("[parent down: %s] %s", strings.Join(parents, ", "), summary)
+	return truncateString(summary, pagerduty.MaxSummaryLength)
+}
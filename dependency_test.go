@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_entityDependencies(t *testing.T) {
+	event := corev2.FixtureEvent("foo", "bar")
+	assert.Empty(t, entityDependencies(event))
+
+	event.Entity.Labels = map[string]string{dependsOnKey: "router1"}
+	assert.Equal(t, []string{"router1"}, entityDependencies(event))
+
+	// the annotation takes precedence over the label, the entity itself is ignored
+	event.Entity.Annotations = map[string]string{dependsOnKey: "hypervisor1, foo, router2"}
+	assert.Equal(t, []string{"hypervisor1", "router2"}, entityDependencies(event))
+}
+
+func Test_manageIncident_dependencies(t *testing.T) {
+	restoreConfig(t)
+	server, sent := newTestEndpoint(t)
+	payload := func(i int) map[string]interface{} {
+		return (*sent)[i]["payload"].(map[string]interface{})
+	}
+
+	tests := []struct {
+		action       string
+		wantStatus   string
+		wantSeverity string
+		wantSummary  string
+	}{
+		{"suppress", skippedStatus, "", ""},
+		{"downgrade", "success", "info", "[parent down: router1] child/disk"},
+		{"mention", "success", "critical", "[parent down: router1] child/disk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			config = testConfig(server.URL)
+			config.stateFile = filepath.Join(t.TempDir(), "state.json")
+			config.dependencyAction = tt.action
+
+			parent := corev2.FixtureEvent("router1", "keepalive")
+			parent.Check.Status = 2
+			_, err := manageIncident(parent, "token", "")
+			require.NoError(t, err)
+
+			*sent = nil
+			child := corev2.FixtureEvent("child", "disk")
+			child.Check.Status = 2
+			child.Entity.Labels = map[string]string{dependsOnKey: "router1,switch1"}
+			result, err := manageIncident(child, "token", "")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, result.Status)
+			if len(tt.wantSeverity) > 0 {
+				require.Len(t, *sent, 1)
+				assert.Equal(t, tt.wantSeverity, payload(0)["severity"])
+				assert.Equal(t, tt.wantSummary, payload(0)["summary"])
+			} else {
+				assert.Empty(t, *sent)
+			}
+
+			// once the parent is resolved, the child trigger is sent as is
+			_, err = manageIncident(corev2.FixtureEvent("router1", "keepalive"), "token", "")
+			require.NoError(t, err)
+			*sent = nil
+			child.Check.Status = 1
+			result, err = manageIncident(child, "token", "")
+			require.NoError(t, err)
+			assert.Equal(t, "success", result.Status)
+			require.Len(t, *sent, 1)
+			assert.Equal(t, "warning", payload(0)["severity"])
+			assert.Equal(t, "child/disk", payload(0)["summary"])
+		})
+	}
+}
+
+func Test_dependencySummary(t *testing.T) {
+	assert.Equal(t, "[parent down: a, b] child/disk", dependencySummary("child/disk", []string{"a", "b"}))
+
+	// long summaries are truncated without splitting characters
+	summary := dependencySummary(strings.Repeat("é", pagerduty.MaxSummaryLength), []string{"router1"})
+	assert.LessOrEqual(t, len(summary), pagerduty.MaxSummaryLength)
+	assert.True(t, utf8.ValidString(summary))
+}
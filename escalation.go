@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// escalationStep raises the severity of a check that has been failing for at
+// least After.
+type escalationStep struct {
+	Severity string
+	After    time.Duration
+}
+
+// parseEscalationSteps parses a comma-separated list of severity:duration
+// pairs, e.g. "error:30m,critical:2h".
+func parseEscalationSteps(value string) ([]escalationStep, error) {
+	var steps []escalationStep
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		severity, after, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid severity escalation step: %s", item)
+		}
+		if _, ok := severityRanks[severity]; !ok {
+			return nil, fmt.Errorf("invalid pagerduty severity: %s", severity)
+		}
+		duration, err := time.ParseDuration(after)
+		if err != nil || duration < 0 {
+			return nil, fmt.Errorf("invalid severity escalation duration: %s", after)
+		}
+		steps = append(steps, escalationStep{Severity: severity, After: duration})
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].After < steps[j].After })
+	return steps, nil
+}
+
+// failingDuration returns how long the check has been failing. It is derived
+// from the last time the check was OK when known, and from the number of
+// consecutive occurrences of the current status otherwise.
+func failingDuration(event *corev2.Event, now time.Time) time.Duration {
+	check := event.Check
+	executed := now
+	if check.Executed > 0 {
+		executed = time.Unix(check.Executed, 0)
+	}
+	if check.LastOK > 0 {
+		return executed.Sub(time.Unix(check.LastOK, 0))
+	}
+	if check.Occurrences > 1 {
+		return time.Duration(check.Occurrences-1) * time.Duration(check.Interval) * time.Second
+	}
+	return 0
+}
+
+// escalateSeverity raises the severity of a failing check to the most severe
+// escalation step it has reached. The severity is never lowered.
+func escalateSeverity(event *corev2.Event, severity string, now time.Time) string {
+	if len(config.escalationSteps) == 0 || event.Check.Status == 0 {
+		return severity
+	}
+
+	failing := failingDuration(event, now)
+	escalated := severity
+	for _, step := range config.escalationSteps {
+		if failing >= step.After && severityRanks[step.Severity] > severityRanks[escalated] {
+			escalated = step.Severity
+		}
+	}
+	if escalated != severity {
+		eventLogger(event).Info(
+			"check has been failing for a while, escalating severity", "failing", failing.String(),
+			"severity", severity, "escalated_severity", escalated,
+		)
+	}
+	return escalated
+}
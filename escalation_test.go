@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseEscalationSteps(t *testing.T) {
+	steps, err := parseEscalationSteps("critical:2h, error:30m")
+	require.NoError(t, err)
+	assert.Equal(t, []escalationStep{
+		{Severity: "error", After: 30 * time.Minute},
+		{Severity: "critical", After: 2 * time.Hour},
+	}, steps)
+
+	_, err = parseEscalationSteps("error")
+	assert.EqualError(t, err, "invalid severity escalation step: error")
+	_, err = parseEscalationSteps("urgent:1h")
+	assert.EqualError(t, err, "invalid pagerduty severity: urgent")
+	_, err = parseEscalationSteps("error:soon")
+	assert.EqualError(t, err, "invalid severity escalation duration: soon")
+}
+
+func Test_failingDuration(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	event := corev2.FixtureEvent("foo", "bar")
+
+	event.Check.Executed = now.Unix()
+	event.Check.LastOK = now.Add(-45 * time.Minute).Unix()
+	assert.Equal(t, 45*time.Minute, failingDuration(event, now))
+
+	event.Check.LastOK = 0
+	event.Check.Interval = 60
+	event.Check.Occurrences = 11
+	assert.Equal(t, 10*time.Minute, failingDuration(event, now))
+
+	event.Check.Occurrences = 1
+	assert.Equal(t, time.Duration(0), failingDuration(event, now))
+}
+
+func Test_escalateSeverity(t *testing.T) {
+	restoreConfig(t)
+
+	steps, err := parseEscalationSteps("error:30m,critical:2h")
+	require.NoError(t, err)
+	config.escalationSteps = steps
+
+	now := time.Unix(1700000000, 0)
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Status = 1
+	event.Check.Executed = now.Unix()
+
+	event.Check.LastOK = now.Add(-10 * time.Minute).Unix()
+	assert.Equal(t, "warning", escalateSeverity(event, "warning", now))
+
+	event.Check.LastOK = now.Add(-time.Hour).Unix()
+	assert.Equal(t, "error", escalateSeverity(event, "warning", now))
+	assert.Equal(t, "critical", escalateSeverity(event, "critical", now))
+
+	event.Check.LastOK = now.Add(-3 * time.Hour).Unix()
+	assert.Equal(t, "critical", escalateSeverity(event, "warning", now))
+
+	event.Check.Status = 0
+	assert.Equal(t, "info", escalateSeverity(event, "info", now))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+)
+
+// eventsAPIVersion is the version of the PagerDuty events API the events are
+// sent with.
+type eventsAPIVersion string
+
+const (
+	v1EventsAPI eventsAPIVersion = "v1"
+	v2EventsAPI eventsAPIVersion = "v2"
+)
+
+func (v eventsAPIVersion) IsValid() bool {
+	switch v {
+	case v1EventsAPI, v2EventsAPI:
+		return true
+	}
+	return false
+}
+
+// responseParsing is how the events API responses are parsed. Strict parsing
+// rejects the responses that aren't PagerDuty JSON responses, lenient parsing
+// accepts them as returned by some PagerDuty agents.
+type responseParsing string
+
+const (
+	strictResponseParsing  responseParsing = "strict"
+	lenientResponseParsing responseParsing = "lenient"
+)
+
+func (p responseParsing) IsValid() bool {
+	switch p {
+	case strictResponseParsing, lenientResponseParsing:
+		return true
+	}
+	return false
+}
+
+// endpointHealth is shared by the events API clients, so that the endpoints
+// which failed are tried last by the next events of a batch.
+var endpointHealth = pagerduty.NewEndpointHealth(time.Minute)
+
+// newClient returns the events API client for the configured API version,
+// region and endpoint. Responses are parsed strictly from the PagerDuty
+// endpoints and leniently from the others, unless --response-parsing is set.
+func newClient() *pagerduty.Client {
+	client := pagerduty.NewClient()
+	if eventsAPIVersion(config.eventsAPI) == v1EventsAPI {
+		client = pagerduty.NewV1Client()
+	}
+	if len(config.region) > 0 {
+		client.Region(pagerduty.Region(config.region))
+	}
+	if len(config.alternateEndpoint) > 0 {
+		client.AlternateEndpoint(config.alternateEndpoint)
+	}
+	if endpoints := splitList(config.failoverEndpoints); len(endpoints) > 0 {
+		client.FailoverEndpoints(endpoints...)
+		client.Health(endpointHealth)
+	}
+	client.Auth(pagerduty.RequestAuth{
+		Headers:     config.endpointHeaders,
+		BearerToken: config.endpointToken,
+		HMACSecret:  config.endpointSecret,
+	})
+	if len(config.responseParsing) > 0 {
+		client.StrictResponses(responseParsing(config.responseParsing) == strictResponseParsing)
+	}
+	return client
+}
+
+// postEvent sends the event with the configured events API version. Events
+// are always built for the v2 API and mapped onto the v1 schema when needed,
+// so that the summary, details and deduplication key are the same.
+func postEvent(ctx context.Context, client *pagerduty.Client, pdEvent *pagerduty.V2Event) (*pagerduty.V2EventResponse, error) {
+	if eventsAPIVersion(config.eventsAPI) != v1EventsAPI {
+		return client.ManageEventWithContext(ctx, pdEvent)
+	}
+	response, err := client.ManageV1EventWithContext(ctx, pagerduty.NewV1Event(pdEvent))
+	if err != nil {
+		return nil, err
+	}
+	return &pagerduty.V2EventResponse{
+		Status:     response.Status,
+		DedupKey:   response.IncidentKey,
+		Message:    response.Message,
+		Errors:     response.Errors,
+		StatusCode: response.StatusCode,
+		Endpoint:   response.Endpoint,
+	}, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_manageIncident_eventsAPIV1(t *testing.T) {
+	restoreConfig(t)
+	server, sent := newTestEndpoint(t)
+	config = testConfig(server.URL)
+	config.eventsAPI = "v1"
+	config.summaryTemplate = "{{.Entity.Name}}/{{.Check.Name}} failed"
+	config.detailsTemplate = "{{.Check.Output}}"
+	config.clientName = "sensu"
+	config.linkAnnotations = true
+
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Status = 2
+	event.Check.Output = "disk full"
+	event.Check.Annotations = map[string]string{"runbook": "https://example.com/runbook"}
+	result, err := manageIncident(event, "service-key", "")
+	require.NoError(t, err)
+	assert.Equal(t, incidentResult{DedupKey: "foo-bar", Action: "trigger", Status: "success"}, result)
+	assert.Equal(t, map[string]interface{}{
+		"service_key":  "service-key",
+		"event_type":   "trigger",
+		"incident_key": "foo-bar",
+		"description":  "foo/bar failed",
+		"details":      "disk full",
+		"client":       "sensu",
+		"contexts": []interface{}{
+			map[string]interface{}{"type": "link", "href": "https://example.com/runbook", "text": "check runbook"},
+		},
+	}, (*sent)[0])
+
+	event.Check.Status = 0
+	_, err = manageIncident(event, "service-key", "")
+	require.NoError(t, err)
+	require.Len(t, *sent, 2)
+	assert.Equal(t, "resolve", (*sent)[1]["event_type"])
+	assert.Equal(t, "foo-bar", (*sent)[1]["incident_key"])
+}
+
+func Test_newClient_responseParsing(t *testing.T) {
+	restoreConfig(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("queued"))
+	}))
+	defer server.Close()
+
+	pdEvent := &pagerduty.V2Event{RoutingKey: "token", Action: "trigger", DedupKey: "foo-bar"}
+	tests := []struct {
+		responseParsing string
+		wantErr         bool
+	}{
+		{"", false},
+		{"lenient", false},
+		{"strict", true},
+	}
+	for _, tt := range tests {
+		config = HandlerConfig{alternateEndpoint: server.URL, responseParsing: tt.responseParsing}
+		response, err := postEvent(context.Background(), newClient(), pdEvent)
+		if tt.wantErr {
+			assert.Error(t, err, tt.responseParsing)
+			continue
+		}
+		require.NoError(t, err, tt.responseParsing)
+		assert.Equal(t, "queued", response.Message)
+		assert.Equal(t, "foo-bar", response.DedupKey)
+	}
+}
+
+func Test_newClient_failover(t *testing.T) {
+	originalConfig := config
+	originalHealth := endpointHealth
+	defer func() {
+		config = originalConfig
+		endpointHealth = originalHealth
+	}()
+	endpointHealth = pagerduty.NewEndpointHealth(time.Minute)
+
+	primaryCode, primaryHits := http.StatusServiceUnavailable, 0
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits++
+		w.WriteHeader(primaryCode)
+		_, _ = w.Write([]byte(`{"status":"error","message":"unavailable"}`))
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"success","dedup_key":"foo-bar"}`))
+	}))
+	defer secondary.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	config = HandlerConfig{
+		alternateEndpoint: primary.URL,
+		failoverEndpoints: down.URL + "," + secondary.URL,
+	}
+	pdEvent := &pagerduty.V2Event{RoutingKey: "token", Action: "trigger", DedupKey: "foo-bar"}
+	response, err := postEvent(context.Background(), newClient(), pdEvent)
+	require.NoError(t, err)
+	assert.Equal(t, secondary.URL, response.Endpoint)
+	assert.Equal(t, 1, primaryHits)
+
+	// the endpoints which failed are tried last
+	response, err = postEvent(context.Background(), newClient(), pdEvent)
+	require.NoError(t, err)
+	assert.Equal(t, secondary.URL, response.Endpoint)
+	assert.Equal(t, 1, primaryHits)
+
+	// client errors don't fail over
+	endpointHealth = pagerduty.NewEndpointHealth(time.Minute)
+	primaryCode = http.StatusBadRequest
+	_, err = postEvent(context.Background(), newClient(), pdEvent)
+	var apiErr pagerduty.EventsAPIV2Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+
+	// the last error is returned when every endpoint fails
+	primaryCode = http.StatusInternalServerError
+	config.failoverEndpoints = down.URL
+	_, err = postEvent(context.Background(), newClient(), pdEvent)
+	assert.ErrorContains(t, err, "all 2 endpoints failed")
+}
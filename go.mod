@@ -3,6 +3,7 @@ module github.com/sensu/sensu-pagerduty-handler
 go 1.23
 
 require (
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/sensu/core/v2 v2.16.1
 	github.com/sensu/sensu-plugin-sdk v0.19.0
 	github.com/stretchr/testify v1.8.0
@@ -27,7 +28,6 @@ require (
 	github.com/pelletier/go-toml v1.8.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/robertkrimen/otto v0.0.0-20221006114523-201ab5b34f52 // indirect
-	github.com/robfig/cron/v3 v3.0.1 // indirect
 	github.com/sensu/sensu-api-tools v0.1.0 // indirect
 	github.com/sensu/sensu-licensing/v2 v2.2.1 // indirect
 	github.com/sirupsen/logrus v1.9.0 // indirect
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestEndpoint starts an Events API endpoint accepting every event. The
+// bodies of the events it receives are appended to the returned slice.
+func newTestEndpoint(t *testing.T) (*httptest.Server, *[]map[string]interface{}) {
+	t.Helper()
+	var sent []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode the event: %v", err)
+		}
+		sent = append(sent, body)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"success"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &sent
+}
+
+// testConfig is the handler configuration of the tests sending events to the
+// endpoint at url.
+func testConfig(url string) HandlerConfig {
+	return HandlerConfig{
+		alternateEndpoint: url,
+		dedupKeyTemplate:  "{{.Entity.Name}}-{{.Check.Name}}",
+		summaryTemplate:   "{{.Entity.Name}}/{{.Check.Name}}",
+		detailsFormat:     "string",
+	}
+}
+
+// restoreConfig restores the handler configuration once the test is done.
+func restoreConfig(t *testing.T) {
+	originalConfig := config
+	t.Cleanup(func() { config = originalConfig })
+}
+
+// sentActions lists the actions and deduplication keys of the sent events.
+func sentActions(sent []map[string]interface{}) []string {
+	actions := make([]string, 0, len(sent))
+	for _, body := range sent {
+		actions = append(actions, body["event_action"].(string)+" "+body["dedup_key"].(string))
+	}
+	return actions
+}
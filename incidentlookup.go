@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+)
+
+// resolvedIncidentPolicy is what to do with a trigger for an incident that
+// was resolved in PagerDuty while the check kept failing.
+type resolvedIncidentPolicy string
+
+const (
+	retriggerResolvedIncidentPolicy resolvedIncidentPolicy = "retrigger"
+	skipResolvedIncidentPolicy      resolvedIncidentPolicy = "skip"
+	noteResolvedIncidentPolicy      resolvedIncidentPolicy = "note"
+)
+
+func (p resolvedIncidentPolicy) IsValid() bool {
+	switch p {
+	case retriggerResolvedIncidentPolicy, skipResolvedIncidentPolicy, noteResolvedIncidentPolicy:
+		return true
+	}
+	return false
+}
+
+// lookupIncident returns the most recent PagerDuty incident with the
+// deduplication key. The result is cached in the state file for
+// --incident-cache-ttl to bound the number of REST API calls. A nil incident
+// means there is none.
+func lookupIncident(ctx context.Context, log *slog.Logger, key, dedupKey string, previous stateRecord) (*cachedIncident, error) {
+	now := time.Now()
+	if previous.Incident != nil && now.Sub(previous.Incident.Checked) < config.incidentTTL {
+		log.Debug("using the cached incident status", "incident_id", previous.Incident.ID, "incident_status", previous.Incident.Status)
+		return previous.Incident, nil
+	}
+
+	incidents, err := newRESTClient().ListIncidents(ctx, pagerduty.ListIncidentsOptions{
+		IncidentKey: dedupKey,
+		SortBy:      "created_at:desc",
+		Limit:       1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incidents: %v", err)
+	}
+	incident := &cachedIncident{Checked: now}
+	if len(incidents) > 0 {
+		incident.ID = incidents[0].ID
+		incident.Status = incidents[0].Status
+	}
+
+	err = updateState(config.stateFile, func(state *handlerState) error {
+		record := state.Incidents[key]
+		record.Incident = incident
+		record.Seen = now
+		state.Incidents[key] = record
+		return nil
+	})
+	if err != nil {
+		log.Warn("failed to update state file", "error", err)
+	}
+
+	if len(incident.ID) == 0 {
+		return nil, nil
+	}
+	return incident, nil
+}
+
+// applyResolvedIncidentPolicy checks whether the incident a trigger is about
+// to be sent again for was resolved in PagerDuty, and applies the configured
+// policy. It reports the status of the event when the trigger must not be
+// sent.
+func applyResolvedIncidentPolicy(ctx context.Context, log *slog.Logger, event *corev2.Event, key, dedupKey string, previous stateRecord) (string, bool) {
+	incident, err := lookupIncident(ctx, log, key, dedupKey, previous)
+	if err != nil {
+		log.Warn("failed to look up the incident status, sending the trigger", "error", err)
+		return "", false
+	}
+	if incident == nil || incident.Status != "resolved" {
+		return "", false
+	}
+
+	log = log.With("incident_id", incident.ID)
+	switch resolvedIncidentPolicy(config.resolvedPolicy) {
+	case skipResolvedIncidentPolicy:
+		log.Info("incident was resolved in PagerDuty, skipping the trigger")
+		return skippedStatus, true
+	case noteResolvedIncidentPolicy:
+		if _, err := newRESTClient().CreateIncidentNote(ctx, incident.ID, incidentNote(event)); err != nil {
+			log.Warn("failed to add incident note, sending the trigger", "error", err)
+			return "", false
+		}
+		log.Info("incident was resolved in PagerDuty, incident note added")
+		return notedStatus, true
+	}
+	log.Info("incident was resolved in PagerDuty, triggering a new incident")
+	return "", false
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_manageIncident_resolvedIncidentPolicy(t *testing.T) {
+	restoreConfig(t)
+	eventsServer, sent := newTestEndpoint(t)
+
+	lookups, notes := 0, 0
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/incidents":
+			lookups++
+			assert.Equal(t, "created_at:desc", r.URL.Query().Get("sort_by"))
+			assert.Equal(t, "1", r.URL.Query().Get("limit"))
+			_, _ = w.Write([]byte(`{"incidents":[{"id":"PABC123","incident_key":"foo-bar","status":"resolved"}],"more":true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/incidents/PABC123/notes":
+			notes++
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"note":{"id":"PNOTE1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer apiServer.Close()
+
+	tests := []struct {
+		policy     string
+		wantStatus string
+		wantEvents int
+		wantNotes  int
+	}{
+		{"retrigger", "success", 2, 0},
+		{"skip", skippedStatus, 1, 0},
+		{"note", notedStatus, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			*sent, lookups, notes = nil, 0, 0
+			config = testConfig(eventsServer.URL)
+			config.stateFile = filepath.Join(t.TempDir(), "state.json")
+			config.apiToken = "api-token"
+			config.apiEndpoint = apiServer.URL
+			config.resolvedPolicy = tt.policy
+			config.incidentTTL = time.Hour
+
+			event := corev2.FixtureEvent("foo", "bar")
+			event.Check.Status = 2
+			result, err := manageIncident(event, "token", "")
+			require.NoError(t, err)
+			assert.Equal(t, "success", result.Status)
+			assert.Equal(t, 0, lookups)
+
+			result, err = manageIncident(event, "token", "")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, result.Status)
+			assert.Len(t, *sent, tt.wantEvents)
+			assert.Equal(t, tt.wantNotes, notes)
+			assert.Equal(t, 1, lookups)
+		})
+	}
+
+	// the incident status is cached until the next trigger is sent
+	lookups, notes = 0, 0
+	config.stateFile = filepath.Join(t.TempDir(), "state.json")
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Status = 2
+	for i := 0; i < 3; i++ {
+		_, err := manageIncident(event, "token", "")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, lookups)
+	assert.Equal(t, 2, notes)
+
+	// a resolve followed by a trigger opens a new incident without lookup
+	_, err := manageIncident(corev2.FixtureEvent("foo", "bar"), "token", "")
+	require.NoError(t, err)
+	_, err = manageIncident(event, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, lookups)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+)
+
+const (
+	keepaliveCheckName      = "keepalive"
+	deregistrationCheckName = "deregistration"
+)
+
+// deregisteredAnnotation marks an event as the deregistration of its entity,
+// for deregistration events that aren't created by the Sensu backend.
+const deregisteredAnnotation = "sensu.io/plugins/sensu-pagerduty-handler/deregistered"
+
+// subscriptionKeepaliveGroup and labelKeepaliveGroupPrefix are the accepted
+// values of --keepalive-group-by.
+const (
+	subscriptionKeepaliveGroup = "subscription"
+	labelKeepaliveGroupPrefix  = "label:"
+)
+
+func isKeepalive(event *corev2.Event) bool {
+	return event.Check.Name == keepaliveCheckName
+}
+
+// isDeregistration reports whether the event was created by the Sensu backend
+// for the deregistration of its entity, or is annotated as such.
+func isDeregistration(event *corev2.Event) bool {
+	if event.Check.Name == deregistrationCheckName {
+		return true
+	}
+	return event.Check.Annotations[deregisteredAnnotation] == "true" ||
+		event.Entity.Annotations[deregisteredAnnotation] == "true"
+}
+
+func validateKeepaliveGroupBy(groupBy string) error {
+	if groupBy == subscriptionKeepaliveGroup {
+		return nil
+	}
+	if label := strings.TrimPrefix(groupBy, labelKeepaliveGroupPrefix); label != groupBy && len(label) > 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid keepalive group by: %s", groupBy)
+}
+
+// keepaliveGroup returns the group of a keepalive event: the first
+// subscription of the entity, skipping its entity:<name> subscription, or the
+// value of an entity label.
+func keepaliveGroup(event *corev2.Event) string {
+	if label := strings.TrimPrefix(config.keepaliveGroupBy, labelKeepaliveGroupPrefix); label != config.keepaliveGroupBy {
+		return event.Entity.Labels[label]
+	}
+	for _, subscription := range event.Entity.Subscriptions {
+		if !strings.HasPrefix(subscription, "entity:") {
+			return subscription
+		}
+	}
+	return ""
+}
+
+// eventForCheck returns a copy of the event for another check of its entity.
+func eventForCheck(event *corev2.Event, name string) *corev2.Event {
+	copied := *event
+	check := *event.Check
+	check.Name = name
+	copied.Check = &check
+	return &copied
+}
+
+// entityIncident is an incident of an entity, identified by its
+// deduplication key.
+type entityIncident struct {
+	DedupKey string
+	Check    string
+}
+
+// entityIncidents returns the incidents to resolve when the entity of the
+// event is deregistered: the incidents triggered for the entity according to
+// the state file, with their recorded deduplication keys. The keepalive key is
+// only computed from the deregistration event without the state file, or when
+// the state file has no keepalive incident for the entity, as it can differ
+// from the key the keepalive incident was triggered with, e.g. with the
+// episode strategy.
+func entityIncidents(event *corev2.Event, contact string) ([]entityIncident, error) {
+	var state *handlerState
+	if len(config.stateFile) > 0 {
+		var err error
+		if state, err = readState(config.stateFile); err != nil {
+			eventLogger(event).Warn("ignoring state file", "error", err)
+		}
+	}
+
+	var (
+		incidents        []entityIncident
+		keepaliveTracked bool
+	)
+	if state != nil {
+		for _, record := range state.Incidents {
+			if record.Namespace != event.Namespace || record.Entity != event.Entity.Name || record.Contact != contact {
+				continue
+			}
+			if record.Check == keepaliveCheckName {
+				keepaliveTracked = true
+			}
+			if record.Action == "trigger" && len(record.DedupKey) > 0 {
+				incidents = append(incidents, entityIncident{DedupKey: record.DedupKey, Check: record.Check})
+			}
+		}
+	}
+	if !keepaliveTracked {
+		keepaliveKey, err := getPagerDutyDedupKey(eventForCheck(event, keepaliveCheckName))
+		if err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, entityIncident{DedupKey: keepaliveKey, Check: keepaliveCheckName})
+	}
+	sort.Slice(incidents, func(i, j int) bool { return incidents[i].DedupKey < incidents[j].DedupKey })
+	return incidents, nil
+}
+
+// resolveEntityIncidents resolves the incidents of a deregistered entity, so
+// that they aren't left open forever. An incident which fails to resolve
+// doesn't prevent resolving the others, the errors are returned together.
+func resolveEntityIncidents(event *corev2.Event, token, contact string) ([]incidentResult, error) {
+	log := eventLogger(event).With("action", "resolve")
+	if len(contact) > 0 {
+		log = log.With("contact", contact)
+	}
+
+	incidents, err := entityIncidents(event, contact)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(config.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	driver := newDriver()
+	labels := metricLabels("namespace", event.Namespace, "destination", maskRoutingKey(token))
+	eventLabels := func(outcome string) map[string]string {
+		outcomeLabels := metricLabels("action", "resolve", "outcome", outcome)
+		for k, v := range labels {
+			outcomeLabels[k] = v
+		}
+		return outcomeLabels
+	}
+
+	results := make([]incidentResult, 0, len(incidents))
+	var errs []error
+	for _, incident := range incidents {
+		dedupKey := incident.DedupKey
+		pdEvent := pagerduty.V2Event{
+			RoutingKey: token,
+			Action:     "resolve",
+			DedupKey:   dedupKey,
+			Client:     config.clientName,
+		}
+		response, err := submitEvent(ctx, log.With("dedup_key", dedupKey), driver, event, &pdEvent, labels)
+		if err != nil {
+			metrics.inc(metricEventsTotal, eventLabels("failure"))
+			errs = append(errs, fmt.Errorf("failed to resolve incident %s: %v", dedupKey, err))
+			continue
+		}
+		metrics.inc(metricEventsTotal, eventLabels("success"))
+		log.Info(
+			"entity was deregistered, incident resolved", "dedup_key", dedupKey, "status", response.Status,
+			"message", response.Message, "endpoint", response.Endpoint,
+		)
+		recordIncident(log, eventForCheck(event, incident.Check), contact, dedupKey, "resolve", "info", true)
+		results = append(results, incidentResult{DedupKey: dedupKey, Action: "resolve", Status: response.Status})
+	}
+	return results, errors.Join(errs...)
+}
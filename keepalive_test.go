@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_keepaliveTemplates(t *testing.T) {
+	restoreConfig(t)
+
+	config.summaryTemplate = "{{.Check.Name}} failed"
+	config.dedupKeyTemplate = "{{.Entity.Namespace}}-{{.Entity.Name}}-{{.Check.Name}}"
+	config.keepaliveSummary = "{{.Entity.Name}} stopped sending keepalives"
+	config.keepaliveDedupKey = "{{.Entity.Name}}-keepalive"
+	config.dedupKeyStrategy = ""
+
+	event := corev2.FixtureEvent("foo", "keepalive")
+	summary, err := getSummary(event)
+	require.NoError(t, err)
+	assert.Equal(t, "foo stopped sending keepalives", summary)
+	key, err := getPagerDutyDedupKey(event)
+	require.NoError(t, err)
+	assert.Equal(t, "foo-keepalive", key)
+
+	event = corev2.FixtureEvent("foo", "bar")
+	summary, err = getSummary(event)
+	require.NoError(t, err)
+	assert.Equal(t, "bar failed", summary)
+	key, err = getPagerDutyDedupKey(event)
+	require.NoError(t, err)
+	assert.Equal(t, "default-foo-bar", key)
+
+	// without keepalive templates, the general templates are used
+	config.keepaliveSummary = ""
+	config.keepaliveDedupKey = ""
+	event = corev2.FixtureEvent("foo", "keepalive")
+	summary, err = getSummary(event)
+	require.NoError(t, err)
+	assert.Equal(t, "keepalive failed", summary)
+	key, err = getPagerDutyDedupKey(event)
+	require.NoError(t, err)
+	assert.Equal(t, "default-foo-keepalive", key)
+}
+
+func Test_keepaliveTemplates_defaults(t *testing.T) {
+	restoreConfig(t)
+
+	defaults := map[string]string{}
+	for _, option := range pagerDutyConfigOptions {
+		if o, ok := option.(*sensu.PluginConfigOption[string]); ok {
+			defaults[o.Argument] = o.Default
+		}
+	}
+	config = testConfig("")
+	config.keepaliveSummary = defaults["keepalive-summary-template"]
+	config.keepaliveDedupKey = defaults["keepalive-dedup-key-template"]
+
+	// keepalive events have their own summary, but keep the general key so
+	// that the keys of the open incidents don't change
+	event := corev2.FixtureEvent("foo", "keepalive")
+	event.Check.Output = "No keepalive sent from foo for 180 seconds"
+	summary, err := getSummary(event)
+	require.NoError(t, err)
+	assert.Equal(t, "foo stopped sending keepalives: No keepalive sent from foo for 180 seconds", summary)
+	key, err := getPagerDutyDedupKey(event)
+	require.NoError(t, err)
+	assert.Equal(t, "foo-keepalive", key)
+}
+
+func Test_keepaliveGroup(t *testing.T) {
+	restoreConfig(t)
+
+	event := corev2.FixtureEvent("foo", "keepalive")
+	event.Entity.Subscriptions = []string{"entity:foo", "linux", "webservers"}
+	event.Entity.Labels = map[string]string{"cluster": "east"}
+
+	config.groupTemplate = "{{.Check.Name}}"
+	group, err := getGroup(event)
+	require.NoError(t, err)
+	assert.Equal(t, "keepalive", group)
+
+	config.keepaliveGroupBy = "subscription"
+	group, err = getGroup(event)
+	require.NoError(t, err)
+	assert.Equal(t, "linux", group)
+
+	config.keepaliveGroupBy = "label:cluster"
+	group, err = getGroup(event)
+	require.NoError(t, err)
+	assert.Equal(t, "east", group)
+
+	// only keepalive events are grouped this way
+	group, err = getGroup(corev2.FixtureEvent("foo", "bar"))
+	require.NoError(t, err)
+	assert.Equal(t, "bar", group)
+
+	assert.NoError(t, validateKeepaliveGroupBy("label:cluster"))
+	assert.EqualError(t, validateKeepaliveGroupBy("label:"), "invalid keepalive group by: label:")
+	assert.EqualError(t, validateKeepaliveGroupBy("cluster"), "invalid keepalive group by: cluster")
+}
+
+func Test_processEvent_deregistration(t *testing.T) {
+	restoreConfig(t)
+
+	var (
+		sent    []string
+		failKey string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		sent = append(sent, body["event_action"].(string)+" "+body["dedup_key"].(string))
+		if body["dedup_key"] == failKey {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"status":"invalid event"}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer server.Close()
+
+	config = testConfig(server.URL)
+	config.authToken = "token"
+	config.keepaliveDedupKey = "{{.Entity.Name}}-keepalive"
+	config.stateFile = filepath.Join(t.TempDir(), "state.json")
+	config.resolveOnDereg = true
+	config.redact = true
+
+	for _, name := range []string{"keepalive", "disk", "cpu"} {
+		event := corev2.FixtureEvent("foo", name)
+		event.Check.Status = 2
+		_, err := processEvent(event)
+		require.NoError(t, err)
+	}
+	// incidents of other entities and resolved incidents are left alone
+	other := corev2.FixtureEvent("bar", "disk")
+	other.Check.Status = 2
+	_, err := processEvent(other)
+	require.NoError(t, err)
+	_, err = processEvent(corev2.FixtureEvent("foo", "cpu"))
+	require.NoError(t, err)
+
+	// an incident which fails to resolve doesn't prevent resolving the others
+	sent = nil
+	failKey = "foo-disk"
+	deregistration := corev2.FixtureEvent("foo", "deregistration")
+	deregistration.Check.Status = 1
+	results, err := manageEvent(deregistration, "token", "")
+	assert.ErrorContains(t, err, "failed to resolve incident foo-disk")
+	assert.Equal(t, []string{"resolve foo-disk", "resolve foo-keepalive"}, sent)
+	assert.Equal(t, []incidentResult{{DedupKey: "foo-keepalive", Action: "resolve", Status: "success"}}, results)
+
+	sent = nil
+	failKey = ""
+	results, err = processEvent(deregistration)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"resolve foo-disk"}, sent)
+	assert.Len(t, results, 1)
+
+	// without the state file, only the keepalive incident is resolved
+	sent = nil
+	config.stateFile = ""
+	deregistration = corev2.FixtureEvent("foo", "check")
+	deregistration.Check.Annotations = map[string]string{deregisteredAnnotation: "true"}
+	_, err = processEvent(deregistration)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"resolve foo-keepalive"}, sent)
+}
+
+func Test_entityIncidents_episode(t *testing.T) {
+	restoreConfig(t)
+
+	config = HandlerConfig{
+		dedupKeyTemplate: "{{.Entity.Name}}-{{.Check.Name}}",
+		dedupKeyStrategy: "episode",
+		stateFile:        filepath.Join(t.TempDir(), "state.json"),
+	}
+	keepalive := corev2.FixtureEvent("foo", "keepalive")
+	keepalive.Check.Status = 2
+	keepalive.Check.LastOK = 1700000000
+	recordIncident(eventLogger(keepalive), keepalive, "", "foo-keepalive-1700000000", "trigger", "critical", true)
+
+	// the deregistration event has another LastOK than the keepalive event,
+	// the key recorded in the state file is resolved
+	deregistration := corev2.FixtureEvent("foo", "deregistration")
+	deregistration.Check.LastOK = 1700000600
+	incidents, err := entityIncidents(deregistration, "")
+	require.NoError(t, err)
+	assert.Equal(t, []entityIncident{{DedupKey: "foo-keepalive-1700000000", Check: "keepalive"}}, incidents)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+type logFormat string
+
+const (
+	logfmtLogFormat logFormat = "logfmt"
+	jsonLogFormat   logFormat = "json"
+)
+
+// logOutput is where the handler logs are written.
+var logOutput io.Writer = os.Stderr
+
+// logger is the handler logger, reconfigured from --log-format and
+// --log-level once the arguments are known.
+var logger = slog.New(slog.NewTextHandler(logOutput, nil))
+
+func setupLogger() error {
+	level := slog.LevelInfo
+	if len(config.logLevel) > 0 {
+		if err := level.UnmarshalText([]byte(config.logLevel)); err != nil {
+			return fmt.Errorf("invalid log level: %s", config.logLevel)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	switch logFormat(config.logFormat) {
+	case "", logfmtLogFormat:
+		logger = slog.New(slog.NewTextHandler(logOutput, opts))
+	case jsonLogFormat:
+		logger = slog.New(slog.NewJSONHandler(logOutput, opts))
+	default:
+		return fmt.Errorf("invalid log format: %s", config.logFormat)
+	}
+	return nil
+}
+
+// eventLogger returns a logger carrying the fields identifying the event.
+func eventLogger(event *corev2.Event) *slog.Logger {
+	l := logger.With("namespace", event.Namespace)
+	if event.Entity != nil {
+		l = l.With("entity", event.Entity.Name)
+	}
+	if event.Check != nil {
+		l = l.With("check", event.Check.Name)
+	}
+	return l
+}
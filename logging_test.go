@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_setupLogger(t *testing.T) {
+	originalConfig := config
+	originalOutput := logOutput
+	originalLogger := logger
+	defer func() {
+		config = originalConfig
+		logOutput = originalOutput
+		logger = originalLogger
+	}()
+
+	var buf bytes.Buffer
+	logOutput = &buf
+
+	config.logFormat = "json"
+	config.logLevel = "info"
+	require.NoError(t, setupLogger())
+
+	event := corev2.FixtureEvent("foo", "bar")
+	eventLogger(event).Debug("hidden")
+	eventLogger(event).Info("visible", "dedup_key", "foo-bar")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "visible", entry["msg"])
+	assert.Equal(t, "INFO", entry["level"])
+	assert.Equal(t, "default", entry["namespace"])
+	assert.Equal(t, "foo", entry["entity"])
+	assert.Equal(t, "bar", entry["check"])
+	assert.Equal(t, "foo-bar", entry["dedup_key"])
+
+	buf.Reset()
+	config.logFormat = "logfmt"
+	config.logLevel = "debug"
+	require.NoError(t, setupLogger())
+	eventLogger(event).Debug("details")
+	assert.Contains(t, buf.String(), `level=DEBUG msg=details namespace=default entity=foo check=bar`)
+
+	config.logFormat = "xml"
+	assert.EqualError(t, setupLogger(), "invalid log format: xml")
+
+	config.logFormat = "json"
+	config.logLevel = "verbose"
+	assert.EqualError(t, setupLogger(), "invalid log level: verbose")
+}
@@ -5,10 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/url"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,24 +23,80 @@ import (
 
 type HandlerConfig struct {
 	sensu.PluginConfig
-	authToken         string
-	dedupKeyTemplate  string
-	statusMapJSON     string
-	summaryTemplate   string
-	teamName          string
-	teamSuffix        string
-	detailsTemplate   string
-	detailsFormat     string
-	alternateEndpoint string
-	contactRouting    bool
-	contacts          []string
-	clientName        string
-	sensuBaseUrl      string
-	linkAnnotations   bool
-	useEventTimestamp bool
-	classTemplate     string
-	groupTemplate     string
-	componentTemplate string
+	authToken            string
+	dedupKeyTemplate     string
+	statusMapJSON        string
+	summaryTemplate      string
+	teamName             string
+	teamSuffix           string
+	detailsTemplate      string
+	detailsFormat        string
+	alternateEndpoint    string
+	contactRouting       bool
+	contacts             []string
+	clientName           string
+	sensuBaseUrl         string
+	linkAnnotations      bool
+	useEventTimestamp    bool
+	classTemplate        string
+	groupTemplate        string
+	componentTemplate    string
+	batchFile            string
+	metricsTextfile      string
+	logFormat            string
+	logLevel             string
+	stateFile            string
+	resendWindow         string
+	resendInterval       time.Duration
+	skipUnneededResolves bool
+	maintenanceFile      string
+	maintenanceWindows   []maintenanceWindow
+	businessHoursFile    string
+	businessHoursRules   []businessHoursRule
+	severityEscalation   string
+	escalationSteps      []escalationStep
+	metricsFilter        string
+	metricsThresholds    string
+	metricsRegexp        *regexp.Regexp
+	thresholds           []metricThreshold
+	detailsInclude       string
+	detailsExclude       string
+	redact               bool
+	redactKeys           string
+	redactPatterns       string
+	redactRegexps        []*regexp.Regexp
+	dedupKeyStrategy     string
+	apiToken             string
+	apiFrom              string
+	apiEndpoint          string
+	incidentNotes        bool
+	resolvedPolicy       string
+	incidentCacheTTL     string
+	incidentTTL          time.Duration
+	keepaliveSummary     string
+	keepaliveDedupKey    string
+	keepaliveGroupBy     string
+	resolveOnDereg       bool
+	stormThreshold       uint64
+	stormWindow          string
+	stormInterval        time.Duration
+	stormGroupTemplate   string
+	dependencyAction     string
+	eventsAPI            string
+	region               string
+	responseParsing      string
+	failoverEndpoints    string
+	output               string
+	webhookURL           string
+	webhookHeaders       map[string]string
+	webhookSecret        string
+	webhookTemplate      string
+	webhookContentType   string
+	endpointHeaders      map[string]string
+	endpointToken        string
+	endpointSecret       string
+	invalidPolicy        string
+	timestampSource      string
 }
 
 type eventStatusMap map[string][]uint32
@@ -47,13 +104,15 @@ type eventStatusMap map[string][]uint32
 type detailsFormat string
 
 const (
-	stringDetailsFormat detailsFormat = "string"
-	jsonDetailsFormat   detailsFormat = "json"
+	stringDetailsFormat  detailsFormat = "string"
+	jsonDetailsFormat    detailsFormat = "json"
+	metricsDetailsFormat detailsFormat = "metrics"
+	curatedDetailsFormat detailsFormat = "curated"
 )
 
 func (df detailsFormat) IsValid() bool {
 	switch df {
-	case stringDetailsFormat, jsonDetailsFormat:
+	case stringDetailsFormat, jsonDetailsFormat, metricsDetailsFormat, curatedDetailsFormat:
 		return true
 	}
 	return false
@@ -108,6 +167,24 @@ var (
 			Value:     &config.dedupKeyTemplate,
 			Default:   "{{.Entity.Name}}-{{.Check.Name}}",
 		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "keepalive-dedup-key-template",
+			Env:       "PAGERDUTY_KEEPALIVE_DEDUP_KEY_TEMPLATE",
+			Argument:  "keepalive-dedup-key-template",
+			Shorthand: "",
+			Usage:     "The PagerDuty V2 API deduplication key template for keepalive events, --dedup-key-template is used when empty, can be set with PAGERDUTY_KEEPALIVE_DEDUP_KEY_TEMPLATE",
+			Value:     &config.keepaliveDedupKey,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "dedup-key-strategy",
+			Env:       "PAGERDUTY_DEDUP_KEY_STRATEGY",
+			Argument:  "dedup-key-strategy",
+			Shorthand: "",
+			Usage:     "How the deduplication key is built from the template ('template', 'hash', 'episode' or 'annotation'), can be set with PAGERDUTY_DEDUP_KEY_STRATEGY",
+			Value:     &config.dedupKeyStrategy,
+			Default:   "template",
+		},
 		&sensu.PluginConfigOption[string]{
 			Path:      "status-map",
 			Env:       "PAGERDUTY_STATUS_MAP",
@@ -140,12 +217,284 @@ var (
 			Env:       "PAGERDUTY_DETAILS_FORMAT",
 			Argument:  "details-format",
 			Shorthand: "",
-			Usage:     "The format of the details output ('string' or 'json'), can be set with PAGERDUTY_DETAILS_FORMAT",
+			Usage:     "The format of the details output ('string', 'json', 'metrics' or 'curated'), can be set with PAGERDUTY_DETAILS_FORMAT",
 			Value:     &config.detailsFormat,
 			Default:   "string",
 		},
 		&sensu.PluginConfigOption[string]{
-			Path:      "alternate-endpoint",
+			Path:      "metrics-filter",
+			Env:       "PAGERDUTY_METRICS_FILTER",
+			Argument:  "metrics-filter",
+			Shorthand: "",
+			Usage:     "Regular expression the names of the metric points shown by the metrics details format must match, can be set with PAGERDUTY_METRICS_FILTER",
+			Value:     &config.metricsFilter,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "metrics-thresholds",
+			Env:       "PAGERDUTY_METRICS_THRESHOLDS",
+			Argument:  "metrics-thresholds",
+			Shorthand: "",
+			Usage:     "Comma-separated thresholds the metric points shown by the metrics details format must cross (e.g. cpu.*>90,disk_free<10), can be set with PAGERDUTY_METRICS_THRESHOLDS",
+			Value:     &config.metricsThresholds,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "details-include",
+			Env:       "PAGERDUTY_DETAILS_INCLUDE",
+			Argument:  "details-include",
+			Shorthand: "",
+			Usage:     "Comma-separated fields the curated details format is restricted to (e.g. entity.name,check.output), can be set with PAGERDUTY_DETAILS_INCLUDE",
+			Value:     &config.detailsInclude,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "details-exclude",
+			Env:       "PAGERDUTY_DETAILS_EXCLUDE",
+			Argument:  "details-exclude",
+			Shorthand: "",
+			Usage:     "Comma-separated fields removed from the curated details format (e.g. annotations,entity.system), can be set with PAGERDUTY_DETAILS_EXCLUDE",
+			Value:     &config.detailsExclude,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[bool]{
+			Path:      "redact",
+			Env:       "PAGERDUTY_REDACT",
+			Argument:  "redact",
+			Shorthand: "",
+			Usage:     "Redact the sensitive values of the events sent to PagerDuty, can be set with PAGERDUTY_REDACT",
+			Value:     &config.redact,
+			Default:   false,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "redact-keys",
+			Env:       "PAGERDUTY_REDACT_KEYS",
+			Argument:  "redact-keys",
+			Shorthand: "",
+			Usage:     "Comma-separated key patterns redacted in addition to the entity redact list (e.g. *password*,*token*), can be set with PAGERDUTY_REDACT_KEYS",
+			Value:     &config.redactKeys,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "redact-patterns",
+			Env:       "PAGERDUTY_REDACT_PATTERNS",
+			Argument:  "redact-patterns",
+			Shorthand: "",
+			Usage:     "Comma-separated regular expressions whose matches are redacted from every string sent to PagerDuty, can be set with PAGERDUTY_REDACT_PATTERNS",
+			Value:     &config.redactPatterns,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "api-token",
+			Env:       "PAGERDUTY_API_TOKEN",
+			Argument:  "api-token",
+			Shorthand: "",
+			Secret:    true,
+			Usage:     "The PagerDuty REST API token, can be set with PAGERDUTY_API_TOKEN",
+			Value:     &config.apiToken,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "api-from",
+			Env:       "PAGERDUTY_API_FROM",
+			Argument:  "api-from",
+			Shorthand: "",
+			Usage:     "The email address of the PagerDuty user the REST API changes are made on behalf of, can be set with PAGERDUTY_API_FROM",
+			Value:     &config.apiFrom,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_API_ENDPOINT",
+			Argument:  "api-endpoint",
+			Shorthand: "",
+			Usage:     "The alternate PagerDuty REST API endpoint, can be set with PAGERDUTY_API_ENDPOINT",
+			Value:     &config.apiEndpoint,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[bool]{
+			Path:      "incident-notes",
+			Env:       "PAGERDUTY_INCIDENT_NOTES",
+			Argument:  "incident-notes",
+			Shorthand: "",
+			Usage:     "Add a note with the check output to the open incident instead of sending the same trigger again, can be set with PAGERDUTY_INCIDENT_NOTES",
+			Value:     &config.incidentNotes,
+			Default:   false,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "resolved-incident-policy",
+			Env:       "PAGERDUTY_RESOLVED_INCIDENT_POLICY",
+			Argument:  "resolved-incident-policy",
+			Shorthand: "",
+			Usage:     "What to do with triggers for incidents resolved in PagerDuty while the check kept failing ('retrigger', 'skip' or 'note'), can be set with PAGERDUTY_RESOLVED_INCIDENT_POLICY",
+			Value:     &config.resolvedPolicy,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "incident-cache-ttl",
+			Env:       "PAGERDUTY_INCIDENT_CACHE_TTL",
+			Argument:  "incident-cache-ttl",
+			Shorthand: "",
+			Usage:     "How long the incident status looked up in PagerDuty is cached in the state file, can be set with PAGERDUTY_INCIDENT_CACHE_TTL",
+			Value:     &config.incidentCacheTTL,
+			Default:   "5m",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "keepalive-summary-template",
+			Env:       "PAGERDUTY_KEEPALIVE_SUMMARY_TEMPLATE",
+			Argument:  "keepalive-summary-template",
+			Shorthand: "",
+			Usage:     "The template for the alert summary of keepalive events, --summary-template is used when empty, can be set with PAGERDUTY_KEEPALIVE_SUMMARY_TEMPLATE",
+			Value:     &config.keepaliveSummary,
+			Default:   "{{.Entity.Name}} stopped sending keepalives: {{.Check.Output}}",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "keepalive-group-by",
+			Env:       "PAGERDUTY_KEEPALIVE_GROUP_BY",
+			Argument:  "keepalive-group-by",
+			Shorthand: "",
+			Usage:     "Group keepalive events by entity subscription ('subscription') or entity label ('label:<name>') in the alert group field, can be set with PAGERDUTY_KEEPALIVE_GROUP_BY",
+			Value:     &config.keepaliveGroupBy,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[bool]{
+			Path:      "resolve-on-deregistration",
+			Env:       "PAGERDUTY_RESOLVE_ON_DEREGISTRATION",
+			Argument:  "resolve-on-deregistration",
+			Shorthand: "",
+			Usage:     "Resolve the incidents of an entity when it is deregistered, can be set with PAGERDUTY_RESOLVE_ON_DEREGISTRATION",
+			Value:     &config.resolveOnDereg,
+			Default:   false,
+		},
+		&sensu.PluginConfigOption[uint64]{
+			Path:      "storm-threshold",
+			Env:       "PAGERDUTY_STORM_THRESHOLD",
+			Argument:  "storm-threshold",
+			Shorthand: "",
+			Usage:     "Number of triggers of the same storm group within the storm window above which triggers are folded into a single incident (0 to disable), can be set with PAGERDUTY_STORM_THRESHOLD",
+			Value:     &config.stormThreshold,
+			Default:   0,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "storm-window",
+			Env:       "PAGERDUTY_STORM_WINDOW",
+			Argument:  "storm-window",
+			Shorthand: "",
+			Usage:     "The window the triggers of a storm group are counted in, can be set with PAGERDUTY_STORM_WINDOW",
+			Value:     &config.stormWindow,
+			Default:   "5m",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "storm-group-template",
+			Env:       "PAGERDUTY_STORM_GROUP_TEMPLATE",
+			Argument:  "storm-group-template",
+			Shorthand: "",
+			Usage:     "The template for the storm group of an event, can be set with PAGERDUTY_STORM_GROUP_TEMPLATE",
+			Value:     &config.stormGroupTemplate,
+			Default:   "{{.Check.Name}}",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "dependency-action",
+			Env:       "PAGERDUTY_DEPENDENCY_ACTION",
+			Argument:  "dependency-action",
+			Shorthand: "",
+			Usage:     "What to do with triggers while an entity listed in the depends_on entity annotation or label has an open incident ('suppress', 'downgrade' or 'mention'), can be set with PAGERDUTY_DEPENDENCY_ACTION",
+			Value:     &config.dependencyAction,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "invalid-event-policy",
+			Env:       "PAGERDUTY_INVALID_EVENT_POLICY",
+			Argument:  "invalid-event-policy",
+			Shorthand: "",
+			Usage:     "What to do with events which violate the PagerDuty event format ('fix' to truncate or normalize the invalid fields, or 'fail'), can be set with PAGERDUTY_INVALID_EVENT_POLICY",
+			Value:     &config.invalidPolicy,
+			Default:   "fix",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "output",
+			Env:       "PAGERDUTY_OUTPUT",
+			Argument:  "output",
+			Shorthand: "",
+			Usage:     "Where the events are sent ('pagerduty' or 'webhook'), can be set with PAGERDUTY_OUTPUT",
+			Value:     &config.output,
+			Default:   "pagerduty",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_WEBHOOK_URL",
+			Argument:  "webhook-url",
+			Shorthand: "",
+			Usage:     "The URL the events are posted to with the webhook output, can be set with PAGERDUTY_WEBHOOK_URL",
+			Value:     &config.webhookURL,
+			Default:   "",
+		},
+		&sensu.MapPluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_WEBHOOK_HEADERS",
+			Argument:  "webhook-headers",
+			Shorthand: "",
+			Usage:     "Headers added to the webhook requests, as name=value pairs, can be set with PAGERDUTY_WEBHOOK_HEADERS",
+			Value:     &config.webhookHeaders,
+			Default:   map[string]string{},
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_WEBHOOK_HMAC_SECRET",
+			Argument:  "webhook-hmac-secret",
+			Shorthand: "",
+			Secret:    true,
+			Usage:     "The secret the HMAC-SHA256 signature of the webhook body is computed with, can be set with PAGERDUTY_WEBHOOK_HMAC_SECRET",
+			Value:     &config.webhookSecret,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "webhook-body-template",
+			Env:       "PAGERDUTY_WEBHOOK_BODY_TEMPLATE",
+			Argument:  "webhook-body-template",
+			Shorthand: "",
+			Usage:     "The template for the webhook body, evaluated with the PagerDuty v2 event, instead of the JSON event, can be set with PAGERDUTY_WEBHOOK_BODY_TEMPLATE",
+			Value:     &config.webhookTemplate,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "webhook-content-type",
+			Env:       "PAGERDUTY_WEBHOOK_CONTENT_TYPE",
+			Argument:  "webhook-content-type",
+			Shorthand: "",
+			Usage:     "The Content-Type of the webhook body, application/json for JSON bodies and text/plain otherwise by default, can be set with PAGERDUTY_WEBHOOK_CONTENT_TYPE",
+			Value:     &config.webhookContentType,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "events-api",
+			Env:       "PAGERDUTY_EVENTS_API",
+			Argument:  "events-api",
+			Shorthand: "",
+			Usage:     "The PagerDuty events API version to send the events with ('v1' or 'v2'), can be set with PAGERDUTY_EVENTS_API",
+			Value:     &config.eventsAPI,
+			Default:   "v2",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "region",
+			Env:       "PAGERDUTY_REGION",
+			Argument:  "region",
+			Shorthand: "",
+			Usage:     "The PagerDuty service region of the account ('us' or 'eu'), can be set with PAGERDUTY_REGION",
+			Value:     &config.region,
+			Default:   "us",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "response-parsing",
+			Env:       "PAGERDUTY_RESPONSE_PARSING",
+			Argument:  "response-parsing",
+			Shorthand: "",
+			Usage:     "How the events API responses are parsed ('strict' or 'lenient'), strict for the PagerDuty endpoints and lenient for the others by default, can be set with PAGERDUTY_RESPONSE_PARSING",
+			Value:     &config.responseParsing,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "",
 			Env:       "PAGERDUTY_ALTERNATE_ENDPOINT",
 			Argument:  "alternate-endpoint",
 			Shorthand: "e",
@@ -153,6 +502,44 @@ var (
 			Value:     &config.alternateEndpoint,
 			Default:   "",
 		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_FAILOVER_ENDPOINTS",
+			Argument:  "failover-endpoints",
+			Shorthand: "",
+			Usage:     "Comma separated list of endpoints tried in order when the PagerDuty or alternate endpoint can't be reached or returns a server error, can be set with PAGERDUTY_FAILOVER_ENDPOINTS",
+			Value:     &config.failoverEndpoints,
+			Default:   "",
+		},
+		&sensu.MapPluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_ENDPOINT_HEADERS",
+			Argument:  "endpoint-headers",
+			Shorthand: "",
+			Usage:     "Headers added to the requests sent to the alternate and failover endpoints, as name=value pairs, values starting with env: or file: are read from an environment variable or a file, can be set with PAGERDUTY_ENDPOINT_HEADERS",
+			Value:     &config.endpointHeaders,
+			Default:   map[string]string{},
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_ENDPOINT_BEARER_TOKEN",
+			Argument:  "endpoint-bearer-token",
+			Shorthand: "",
+			Secret:    true,
+			Usage:     "The bearer token sent to the alternate and failover endpoints, can be set with PAGERDUTY_ENDPOINT_BEARER_TOKEN",
+			Value:     &config.endpointToken,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_ENDPOINT_HMAC_SECRET",
+			Argument:  "endpoint-hmac-secret",
+			Shorthand: "",
+			Secret:    true,
+			Usage:     "The secret the HMAC-SHA256 signature of the body sent to the alternate and failover endpoints is computed with, can be set with PAGERDUTY_ENDPOINT_HMAC_SECRET",
+			Value:     &config.endpointSecret,
+			Default:   "",
+		},
 		&sensu.PluginConfigOption[uint64]{
 			Path:      "timeout",
 			Env:       "PAGERDUTY_TIMEOUT",
@@ -205,6 +592,15 @@ var (
 			Value:     &config.useEventTimestamp,
 			Default:   false,
 		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "timestamp-source",
+			Env:       "PAGERDUTY_TIMESTAMP_SOURCE",
+			Argument:  "timestamp-source",
+			Shorthand: "",
+			Usage:     "The source of the PD-CEF timestamp field ('event', 'executed', 'issued' or 'now'), can be set with PAGERDUTY_TIMESTAMP_SOURCE",
+			Value:     &config.timestampSource,
+			Default:   "",
+		},
 		&sensu.PluginConfigOption[string]{
 			Path:      "class-template",
 			Env:       "PAGERDUTY_CLASS_TEMPLATE",
@@ -232,10 +628,106 @@ var (
 			Value:     &config.componentTemplate,
 			Default:   "",
 		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_BATCH_FILE",
+			Argument:  "batch-file",
+			Shorthand: "",
+			Usage:     "Process newline-delimited Sensu events read from this file ('-' for stdin) instead of a single event, can be set with PAGERDUTY_BATCH_FILE",
+			Value:     &config.batchFile,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_METRICS_TEXTFILE",
+			Argument:  "metrics-textfile",
+			Shorthand: "",
+			Usage:     "Path of a Prometheus textfile collector file where handler metrics are accumulated, can be set with PAGERDUTY_METRICS_TEXTFILE",
+			Value:     &config.metricsTextfile,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "log-format",
+			Env:       "PAGERDUTY_LOG_FORMAT",
+			Argument:  "log-format",
+			Shorthand: "",
+			Usage:     "The format of the handler logs ('logfmt' or 'json'), can be set with PAGERDUTY_LOG_FORMAT",
+			Value:     &config.logFormat,
+			Default:   string(logfmtLogFormat),
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "log-level",
+			Env:       "PAGERDUTY_LOG_LEVEL",
+			Argument:  "log-level",
+			Shorthand: "",
+			Usage:     "The minimum level of the handler logs ('debug', 'info', 'warn' or 'error'), can be set with PAGERDUTY_LOG_LEVEL",
+			Value:     &config.logLevel,
+			Default:   "info",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_STATE_FILE",
+			Argument:  "state-file",
+			Shorthand: "",
+			Usage:     "Path of the file where the handler keeps track of the incidents it sent, can be set with PAGERDUTY_STATE_FILE",
+			Value:     &config.stateFile,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "resend-window",
+			Env:       "PAGERDUTY_RESEND_WINDOW",
+			Argument:  "resend-window",
+			Shorthand: "",
+			Usage:     "Skip triggers with an unchanged severity sent again within this duration (e.g. 30m), requires --state-file, can be set with PAGERDUTY_RESEND_WINDOW",
+			Value:     &config.resendWindow,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[bool]{
+			Path:      "skip-unneeded-resolves",
+			Env:       "PAGERDUTY_SKIP_UNNEEDED_RESOLVES",
+			Argument:  "skip-unneeded-resolves",
+			Shorthand: "",
+			Usage:     "Only send a resolve when the check was previously failing, or when a trigger was recorded in the state file, can be set with PAGERDUTY_SKIP_UNNEEDED_RESOLVES",
+			Value:     &config.skipUnneededResolves,
+			Default:   false,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_MAINTENANCE_FILE",
+			Argument:  "maintenance-file",
+			Shorthand: "",
+			Usage:     "Path of a JSON file describing maintenance windows during which triggers are dropped, downgraded or deferred, can be set with PAGERDUTY_MAINTENANCE_FILE",
+			Value:     &config.maintenanceFile,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "",
+			Env:       "PAGERDUTY_BUSINESS_HOURS_FILE",
+			Argument:  "business-hours-file",
+			Shorthand: "",
+			Usage:     "Path of a JSON file describing business hours outside of which the severity is downgraded, can be set with PAGERDUTY_BUSINESS_HOURS_FILE",
+			Value:     &config.businessHoursFile,
+			Default:   "",
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "severity-escalation",
+			Env:       "PAGERDUTY_SEVERITY_ESCALATION",
+			Argument:  "severity-escalation",
+			Shorthand: "",
+			Usage:     "Comma-separated severity:duration steps raising the severity of checks failing for that long (e.g. error:30m,critical:2h), can be set with PAGERDUTY_SEVERITY_ESCALATION",
+			Value:     &config.severityEscalation,
+			Default:   "",
+		},
 	}
 )
 
 func main() {
+	if batchModeRequested(os.Args[1:]) {
+		batch := sensu.NewCheck(&config.PluginConfig, pagerDutyConfigOptions, checkBatchArgs, executeBatch, false)
+		batch.Execute()
+		return
+	}
+
 	//goHandler := sensu.NewGoHandler(&config.PluginConfig, pagerDutyConfigOptions, checkArgs, handleEvent)
 	goHandler := sensu.NewHandler(&config.PluginConfig, pagerDutyConfigOptions, checkArgs, handleEvent)
 	goHandler.Execute()
@@ -263,21 +755,44 @@ func getTeamToken() (string, error) {
 	if len(teamEnvVar) == 0 {
 		return "", fmt.Errorf("unknown problem with team evironment variable")
 	}
-	log.Printf("Looking up token envvar: %s", teamEnvVar)
+	logger.Info("looking up team token", "envvar", teamEnvVar)
 	teamToken := os.Getenv(teamEnvVar)
 	if len(teamToken) == 0 {
-		log.Printf("Token envvar %s is empty, using default token instead", teamEnvVar)
+		logger.Info("team token envvar is empty, using default token instead", "envvar", teamEnvVar)
 	} else {
-		log.Printf("Token envvar %s found, replacing default token", teamEnvVar)
+		logger.Info("team token envvar found, replacing default token", "envvar", teamEnvVar)
 	}
 	return teamToken, err
 }
 
+// checkKeyspaceAnnotation rejects the events annotated with the bare
+// annotations keyspace. The SDK reads the options which have no annotation
+// path, such as the endpoints and their credentials, from that annotation, so
+// it would let an agent override them.
+func checkKeyspaceAnnotation(event *corev2.Event) error {
+	if len(config.Keyspace) == 0 {
+		return nil
+	}
+	if (event.Check != nil && len(event.Check.Annotations[config.Keyspace]) > 0) ||
+		(event.Entity != nil && len(event.Entity.Annotations[config.Keyspace]) > 0) {
+		return fmt.Errorf("the %s annotation is not supported, annotate the options with %s/<option>", config.Keyspace, config.Keyspace)
+	}
+	return nil
+}
+
 func checkArgs(event *corev2.Event) error {
 	if !event.HasCheck() {
 		return errors.New("event does not contain check")
 	}
 
+	if err := checkKeyspaceAnnotation(event); err != nil {
+		return err
+	}
+
+	if err := setupLogger(); err != nil {
+		return err
+	}
+
 	if len(config.teamName) != 0 {
 		teamToken, err := getTeamToken()
 		if err != nil {
@@ -298,56 +813,247 @@ func checkArgs(event *corev2.Event) error {
 		}
 		config.contacts = contacts
 	} else {
-		if len(config.authToken) == 0 {
+		if len(config.authToken) == 0 && outputType(config.output) != webhookOutput {
 			return errors.New("no auth token provided")
 		}
 	}
 
+	if len(config.dedupKeyStrategy) > 0 && !dedupKeyStrategy(config.dedupKeyStrategy).IsValid() {
+		return fmt.Errorf("invalid dedup key strategy: %s", config.dedupKeyStrategy)
+	}
+
 	if !detailsFormat(config.detailsFormat).IsValid() {
 		return fmt.Errorf("invalid details format: %s", config.detailsFormat)
 	}
 
+	if len(config.metricsFilter) != 0 {
+		re, err := regexp.Compile(config.metricsFilter)
+		if err != nil {
+			return fmt.Errorf("invalid metrics filter: %v", err)
+		}
+		config.metricsRegexp = re
+	}
+
+	if len(config.metricsThresholds) != 0 {
+		thresholds, err := parseMetricThresholds(config.metricsThresholds)
+		if err != nil {
+			return err
+		}
+		config.thresholds = thresholds
+	}
+
+	if err := validateRedactKeys(config.redactKeys); err != nil {
+		return err
+	}
+	patterns, err := parseRedactPatterns(config.redactPatterns)
+	if err != nil {
+		return err
+	}
+	config.redactRegexps = patterns
+
 	if len(config.alternateEndpoint) != 0 {
 		if _, err := url.Parse(config.alternateEndpoint); err != nil {
 			return fmt.Errorf("invalid alternate endpoint: %s", config.alternateEndpoint)
 		}
 	}
 
+	if len(config.resendWindow) != 0 {
+		if len(config.stateFile) == 0 {
+			return errors.New("--resend-window requires --state-file")
+		}
+		window, err := time.ParseDuration(config.resendWindow)
+		if err != nil {
+			return fmt.Errorf("invalid resend window: %s", config.resendWindow)
+		}
+		config.resendInterval = window
+	}
+
+	if config.stormThreshold > 0 {
+		if len(config.stateFile) == 0 {
+			return errors.New("--storm-threshold requires --state-file")
+		}
+		window, err := time.ParseDuration(config.stormWindow)
+		if err != nil || window <= 0 {
+			return fmt.Errorf("invalid storm window: %s", config.stormWindow)
+		}
+		config.stormInterval = window
+	}
+
+	if len(config.timestampSource) != 0 && !timestampSource(config.timestampSource).IsValid() {
+		return fmt.Errorf("invalid timestamp source: %s", config.timestampSource)
+	}
+	if len(config.invalidPolicy) != 0 && !invalidEventPolicy(config.invalidPolicy).IsValid() {
+		return fmt.Errorf("invalid invalid event policy: %s", config.invalidPolicy)
+	}
+	if len(config.output) != 0 && !outputType(config.output).IsValid() {
+		return fmt.Errorf("invalid output: %s", config.output)
+	}
+	if outputType(config.output) == webhookOutput && len(config.webhookURL) == 0 {
+		return errors.New("--output webhook requires --webhook-url")
+	}
+	webhookHeaders, err := resolveHeaders(config.webhookHeaders)
+	if err != nil {
+		return fmt.Errorf("invalid webhook headers: %v", err)
+	}
+	config.webhookHeaders = webhookHeaders
+	endpointHeaders, err := resolveHeaders(config.endpointHeaders)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint headers: %v", err)
+	}
+	config.endpointHeaders = endpointHeaders
+	if len(config.eventsAPI) != 0 && !eventsAPIVersion(config.eventsAPI).IsValid() {
+		return fmt.Errorf("invalid events api: %s", config.eventsAPI)
+	}
+	if len(config.region) != 0 && !pagerduty.Region(config.region).IsValid() {
+		return fmt.Errorf("invalid region: %s", config.region)
+	}
+	if len(config.responseParsing) != 0 && !responseParsing(config.responseParsing).IsValid() {
+		return fmt.Errorf("invalid response parsing: %s", config.responseParsing)
+	}
+
+	if len(config.dependencyAction) != 0 {
+		if !dependencyAction(config.dependencyAction).IsValid() {
+			return fmt.Errorf("invalid dependency action: %s", config.dependencyAction)
+		}
+		if len(config.stateFile) == 0 {
+			return errors.New("--dependency-action requires --state-file")
+		}
+	}
+
+	if len(config.keepaliveGroupBy) != 0 {
+		if err := validateKeepaliveGroupBy(config.keepaliveGroupBy); err != nil {
+			return err
+		}
+	}
+
+	if len(config.resolvedPolicy) != 0 {
+		if !resolvedIncidentPolicy(config.resolvedPolicy).IsValid() {
+			return fmt.Errorf("invalid resolved incident policy: %s", config.resolvedPolicy)
+		}
+		if len(config.stateFile) == 0 {
+			return errors.New("--resolved-incident-policy requires --state-file")
+		}
+		if len(config.apiToken) == 0 {
+			return errors.New("--resolved-incident-policy requires --api-token")
+		}
+	}
+
+	if len(config.incidentCacheTTL) != 0 {
+		ttl, err := time.ParseDuration(config.incidentCacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid incident cache ttl: %s", config.incidentCacheTTL)
+		}
+		config.incidentTTL = ttl
+	}
+
+	if config.incidentNotes {
+		if len(config.stateFile) == 0 {
+			return errors.New("--incident-notes requires --state-file")
+		}
+		if len(config.apiToken) == 0 {
+			return errors.New("--incident-notes requires --api-token")
+		}
+	}
+
+	if len(config.apiEndpoint) != 0 {
+		if _, err := url.Parse(config.apiEndpoint); err != nil {
+			return fmt.Errorf("invalid api endpoint: %s", config.apiEndpoint)
+		}
+	}
+
+	if len(config.maintenanceFile) != 0 {
+		windows, err := loadMaintenanceWindows(config.maintenanceFile)
+		if err != nil {
+			return err
+		}
+		for _, window := range windows {
+			if window.Action == deferMaintenanceAction && len(config.stateFile) == 0 {
+				return fmt.Errorf("maintenance window %q: the defer action requires --state-file", window.Name)
+			}
+		}
+		config.maintenanceWindows = windows
+	}
+
+	if len(config.businessHoursFile) != 0 {
+		rules, err := loadBusinessHoursRules(config.businessHoursFile)
+		if err != nil {
+			return err
+		}
+		config.businessHoursRules = rules
+	}
+
+	if len(config.severityEscalation) != 0 {
+		steps, err := parseEscalationSteps(config.severityEscalation)
+		if err != nil {
+			return err
+		}
+		config.escalationSteps = steps
+	}
+
 	return nil
 }
 
 func handleEvent(event *corev2.Event) error {
+	defer flushMetrics()
+	_, err := processEvent(event)
+	return err
+}
+
+// processEvent sends the event to PagerDuty, once per contact when contact
+// routing is enabled, and returns the outcome of every submission.
+func processEvent(event *corev2.Event) ([]incidentResult, error) {
 	if config.contactRouting {
 		return handleEventContactRouting(event)
 	}
-	return manageIncident(event, config.authToken)
+	return manageEvent(event, config.authToken, "")
+}
+
+// manageEvent manages the incident of the event, or resolves the incidents
+// of the entity when the event is its deregistration.
+func manageEvent(event *corev2.Event, token, contact string) ([]incidentResult, error) {
+	if config.resolveOnDereg && isDeregistration(event) {
+		return resolveEntityIncidents(event, token, contact)
+	}
+	result, err := manageIncident(event, token, contact)
+	if err != nil {
+		return nil, err
+	}
+	return []incidentResult{result}, nil
 }
 
-func handleEventContactRouting(event *corev2.Event) error {
+func handleEventContactRouting(event *corev2.Event) ([]incidentResult, error) {
 	errd := false
 	contacts := config.contacts
-	log.Printf("Contact routing is enabled (contacts: %s)", strings.Join(contacts, ", "))
+	eventLogger(event).Info("contact routing is enabled", "contacts", strings.Join(contacts, ", "))
 
+	results := make([]incidentResult, 0, len(contacts))
 	for _, contact := range contacts {
-		if err := handleEventForContact(event, contact); err != nil {
-			log.Printf("WARNING: skipping contact \"%s\" (%s)", contact, err)
+		contactResults, err := handleEventForContact(event, contact)
+		if err != nil {
+			eventLogger(event).Warn("skipping contact", "contact", contact, "error", err)
 			errd = true
+			continue
 		}
+		results = append(results, contactResults...)
 	}
 
 	if errd {
-		return errors.New("handler execution error for one or more contacts")
+		return results, errors.New("handler execution error for one or more contacts")
 	}
-	return nil
+	return results, nil
 }
 
-func handleEventForContact(event *corev2.Event, contact string) error {
+func handleEventForContact(event *corev2.Event, contact string) ([]incidentResult, error) {
 	token, err := getContactToken(contact)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return manageIncident(event, token)
+	results, err := manageEvent(event, token, contact)
+	for i := range results {
+		results[i].Contact = contact
+	}
+	return results, err
 }
 
 func validateContacts(contacts []string) error {
@@ -396,7 +1102,44 @@ func getContactToken(contact string) (string, error) {
 	return token, nil
 }
 
-func manageIncident(event *corev2.Event, token string) error {
+// skippedStatus is the status reported for events that weren't sent.
+const skippedStatus = "skipped"
+
+// incidentResult describes the outcome of a single event submission.
+type incidentResult struct {
+	Contact  string
+	DedupKey string
+	Action   string
+	Status   string
+}
+
+func manageIncident(event *corev2.Event, token, contact string) (result incidentResult, err error) {
+	action := "trigger"
+
+	if event.Check.Status == 0 {
+		action = "resolve"
+	}
+
+	log := eventLogger(event).With("action", action)
+	if len(contact) > 0 {
+		log = log.With("contact", contact)
+	}
+
+	labels := metricLabels("namespace", event.Namespace, "destination", maskRoutingKey(token))
+	defer func() {
+		outcome := "success"
+		if err != nil {
+			outcome = "failure"
+		} else if result.Status == skippedStatus || result.Status == notedStatus {
+			outcome = result.Status
+		}
+		eventLabels := metricLabels("action", action, "outcome", outcome)
+		for k, v := range labels {
+			eventLabels[k] = v
+		}
+		metrics.inc(metricEventsTotal, eventLabels)
+	}()
+
 	ctx := context.Background()
 	if config.Timeout > 0 {
 		var cancel context.CancelFunc
@@ -406,38 +1149,63 @@ func manageIncident(event *corev2.Event, token string) error {
 
 	severity, err := getPagerDutySeverity(event, config.statusMapJSON)
 	if err != nil {
-		return err
+		return incidentResult{}, err
+	}
+	log.Info("incident severity", "severity", severity)
+
+	window, inMaintenance := activeMaintenanceWindow(event, time.Now())
+	inMaintenance = inMaintenance && action == "trigger"
+	if inMaintenance {
+		log = log.With("maintenance_window", window.Name)
+		if window.Action == infoMaintenanceAction {
+			log.Info("maintenance window is active, sending the trigger with the info severity")
+			severity = "info"
+		}
+	}
+
+	var parents []string
+	if len(config.dependencyAction) > 0 && action == "trigger" {
+		if parents = downParents(log, event); len(parents) > 0 {
+			log = log.With("parents_down", strings.Join(parents, ","))
+			if dependencyAction(config.dependencyAction) == downgradeDependencyAction {
+				log.Info("parent entity is down, sending the trigger with the info severity")
+				severity = "info"
+			}
+		}
 	}
-	log.Printf("Incident severity: %s", severity)
 
 	summary, err := getSummary(event)
 	if err != nil {
-		return err
+		return incidentResult{}, err
+	}
+	if len(parents) > 0 {
+		summary = dependencySummary(summary, parents)
 	}
 
 	details, err := getDetails(event)
 	if err != nil {
-		return err
+		return incidentResult{}, err
 	}
 
 	group, err := getGroup(event)
 	if err != nil {
-		return err
+		return incidentResult{}, err
 	}
 
 	component, err := getComponent(event)
 	if err != nil {
-		return err
+		return incidentResult{}, err
 	}
 
 	class, err := getClass(event)
 	if err != nil {
-		return err
+		return incidentResult{}, err
 	}
 
 	// "The maximum permitted length of PG event is 512 KB. Let's limit check output to 256KB to prevent triggering a failed send"
 	if len(event.Check.Output) > 256000 {
-		log.Printf("Warning Incident Payload Truncated!")
+		log.Warn("incident payload truncated", "field", "check_output")
+		metrics.inc(metricTruncationsTotal, metricLabels("namespace", event.Namespace, "field", "check_output"))
 		event.Check.Output = "WARNING Truncated:i\n" + event.Check.Output[:256000] + "..."
 	}
 
@@ -452,18 +1220,92 @@ func manageIncident(event *corev2.Event, token string) error {
 		Timestamp: getTimestamp(event),
 	}
 
-	action := "trigger"
+	dedupKey, err := getPagerDutyDedupKey(event)
+	if err != nil {
+		return incidentResult{}, err
+	}
+	if err := validateDedupKey(dedupKey); err != nil {
+		return incidentResult{}, err
+	}
 
-	if event.Check.Status == 0 {
-		action = "resolve"
+	var state *handlerState
+	if len(config.stateFile) > 0 {
+		if state, err = readState(config.stateFile); err != nil {
+			log.Warn("ignoring state file", "error", err, "dedup_key", dedupKey)
+		}
 	}
+	if state != nil && action == "resolve" && dedupKeyStrategy(config.dedupKeyStrategy) == episodeDedupKeyStrategy {
+		if episodeKey, ok := openEpisodeKey(state, contact, episodeBaseKey(dedupKey, event)); ok && episodeKey != dedupKey {
+			log.Info("resolving the open failure episode", "episode_dedup_key", episodeKey)
+			dedupKey = episodeKey
+		}
+	}
+	log = log.With("dedup_key", dedupKey)
 
-	dedupKey, err := getPagerDutyDedupKey(event)
-	if err != nil {
-		return err
+	key := stateKey(contact, dedupKey)
+	var (
+		previous stateRecord
+		found    bool
+	)
+	if state != nil {
+		previous, found = state.Incidents[key]
+	}
+	// a trigger deferred by a maintenance window must be sent once the window
+	// is over, even when it would otherwise be skipped or added as a note
+	deferred := found && previous.Deferred && action == "trigger" && !inMaintenance
+	if !deferred && isRedundantTrigger(previous, found, action, severity, time.Now(), config.resendInterval) {
+		log.Info("skipping trigger, nothing changed since it was last sent", "last_sent", previous.Sent)
+		recordIncident(log, event, contact, dedupKey, action, severity, false)
+		return incidentResult{DedupKey: dedupKey, Action: action, Status: skippedStatus}, nil
+	}
+	if inMaintenance {
+		switch window.Action {
+		case dropMaintenanceAction:
+			log.Info("maintenance window is active, dropping the trigger")
+			recordIncident(log, event, contact, dedupKey, action, severity, false)
+			return incidentResult{DedupKey: dedupKey, Action: action, Status: skippedStatus}, nil
+		case deferMaintenanceAction:
+			log.Info("maintenance window is active, deferring the trigger")
+			deferIncident(log, event, contact, dedupKey)
+			return incidentResult{DedupKey: dedupKey, Action: action, Status: skippedStatus}, nil
+		}
+	}
+	if len(parents) > 0 && dependencyAction(config.dependencyAction) == suppressDependencyAction {
+		log.Info("parent entity is down, suppressing the trigger")
+		recordIncident(log, event, contact, dedupKey, action, severity, false)
+		return incidentResult{DedupKey: dedupKey, Action: action, Status: skippedStatus}, nil
+	}
+	if len(config.resolvedPolicy) > 0 && action == "trigger" && found && previous.Action == "trigger" {
+		if status, handled := applyResolvedIncidentPolicy(ctx, log, event, key, dedupKey, previous); handled {
+			recordIncident(log, event, contact, dedupKey, action, severity, false)
+			return incidentResult{DedupKey: dedupKey, Action: action, Status: status}, nil
+		}
+	}
+	if config.incidentNotes && !deferred && action == "trigger" && found && previous.Action == "trigger" && previous.Severity == severity {
+		noted, err := addIncidentNote(ctx, log, event, dedupKey)
+		if err != nil {
+			log.Warn("failed to add incident note, sending the trigger", "error", err)
+		} else if noted {
+			recordIncident(log, event, contact, dedupKey, action, severity, false)
+			return incidentResult{DedupKey: dedupKey, Action: action, Status: notedStatus}, nil
+		}
+	}
+	if config.stormThreshold > 0 {
+		ownIncidentOpen := found && previous.Action == "trigger"
+		result, folded, err := handleStorm(ctx, log, event, token, contact, dedupKey, action, severity, ownIncidentOpen, labels)
+		if folded || err != nil {
+			return result, err
+		}
 	}
-	if len(dedupKey) == 0 {
-		return fmt.Errorf("pagerduty dedup key is empty")
+	// the folded triggers of a storm aren't recorded, the storm decides
+	// whether their resolves are needed first
+	if action == "resolve" && config.skipUnneededResolves && !isResolveNeeded(event, previous, found) {
+		log.Info("skipping resolve, no incident was triggered")
+		recordIncident(log, event, contact, dedupKey, action, severity, false)
+		return incidentResult{DedupKey: dedupKey, Action: action, Status: skippedStatus}, nil
+	}
+	if deferred {
+		log.Info("sending the trigger deferred by a maintenance window")
 	}
 	pdEvent := pagerduty.V2Event{
 		RoutingKey: token,
@@ -474,21 +1316,23 @@ func manageIncident(event *corev2.Event, token string) error {
 		ClientURL:  getClientUrl(event),
 		Links:      getLinks(event),
 	}
-
-	client := pagerduty.NewClient()
-	if len(config.alternateEndpoint) > 0 {
-		client.AlternateEndpoint(config.alternateEndpoint)
+	if err := prepareEvent(log, event, &pdEvent); err != nil {
+		return incidentResult{}, err
 	}
 
-	eventResponse, err := client.ManageEventWithContext(ctx, &pdEvent)
+	driver := newDriver()
+
+	eventResponse, err := sendEvent(ctx, driver, &pdEvent, labels)
 	if err != nil {
-		log.Printf("Warning Event Send failed, sending fallback event\n %s", err.Error())
+		log.Warn("event send failed, sending fallback event", "attempt", 1, "error", err)
+		metrics.inc(metricRetriesTotal, labels)
+		metrics.inc(metricFallbackTotal, labels)
 		failPayload := pagerduty.V2Payload{
 			Source:    event.Entity.Name,
 			Component: event.Check.Name,
 			Severity:  severity,
 			Summary:   summary,
-			Details:   "Original payload had an error, maybe due to event length. PagerDuty Events must be less than 512KB",
+			Details:   fallbackDetails,
 		}
 		failEvent := pagerduty.V2Event{
 			RoutingKey: token,
@@ -496,28 +1340,121 @@ func manageIncident(event *corev2.Event, token string) error {
 			Payload:    &failPayload,
 			DedupKey:   dedupKey,
 		}
+		if err := prepareEvent(log, event, &failEvent); err != nil {
+			return incidentResult{}, err
+		}
 
-		failResponse, err := client.ManageEventWithContext(ctx, &failEvent)
+		failResponse, err := sendEvent(ctx, driver, &failEvent, labels)
 		if err != nil {
-			return err
+			return incidentResult{}, err
 		}
 		// FUTURE send to AH
-		log.Printf(
-			"Failback event (%s) submitted to PagerDuty, Status: %s, Dedup Key: %s, Message: %s", action,
-			failResponse.Status, failResponse.DedupKey, failResponse.Message,
+		log.Info(
+			"fallback event submitted to PagerDuty", "attempt", 2, "status", failResponse.Status,
+			"message", failResponse.Message, "endpoint", failResponse.Endpoint,
 		)
+		recordIncident(log, event, contact, dedupKey, action, severity, true)
+		return incidentResult{DedupKey: dedupKey, Action: action, Status: failResponse.Status}, nil
 	}
 
 	// FUTURE send to AH
-	log.Printf(
-		"Event (%s) submitted to PagerDuty, Status: %s, Dedup Key: %s, Message: %s", action, eventResponse.Status,
-		eventResponse.DedupKey, eventResponse.Message,
+	log.Info(
+		"event submitted to PagerDuty", "attempt", 1, "status", eventResponse.Status,
+		"message", eventResponse.Message, "endpoint", eventResponse.Endpoint,
 	)
-	return nil
+	recordIncident(log, event, contact, dedupKey, action, severity, true)
+	return incidentResult{DedupKey: dedupKey, Action: action, Status: eventResponse.Status}, nil
+}
+
+// fallbackDetails replaces the details of events rejected by PagerDuty, most
+// likely because of their size.
+const fallbackDetails = "Original payload had an error, maybe due to event length. PagerDuty Events must be less than 512KB"
+
+// prepareEvent redacts and validates the PagerDuty event built from the Sensu
+// event. Every event sent by the handler goes through it, including the
+// fallback, storm and deregistration events.
+func prepareEvent(log *slog.Logger, event *corev2.Event, pdEvent *pagerduty.V2Event) error {
+	if config.redact {
+		if err := redactEvent(event, pdEvent); err != nil {
+			return err
+		}
+	}
+	return checkEvent(log, pdEvent)
+}
+
+// submitEvent prepares and sends an event which has no fallback.
+func submitEvent(ctx context.Context, log *slog.Logger, driver outputDriver, event *corev2.Event, pdEvent *pagerduty.V2Event, labels map[string]string) (*pagerduty.V2EventResponse, error) {
+	if err := prepareEvent(log, event, pdEvent); err != nil {
+		return nil, err
+	}
+	return sendEvent(ctx, driver, pdEvent, labels)
+}
+
+// sendEvent submits an event with the output driver, recording the latency
+// and the response status code of the request.
+func sendEvent(ctx context.Context, driver outputDriver, pdEvent *pagerduty.V2Event, labels map[string]string) (*pagerduty.V2EventResponse, error) {
+	start := time.Now()
+	response, err := driver.send(ctx, pdEvent)
+	metrics.observe(metricSendDuration, labels, time.Since(start))
+
+	code := "error"
+	var (
+		apiErr  pagerduty.EventsAPIV2Error
+		hookErr webhookError
+	)
+	if err == nil {
+		code = strconv.Itoa(response.StatusCode)
+	} else if errors.As(err, &apiErr) {
+		code = strconv.Itoa(apiErr.StatusCode)
+	} else if errors.As(err, &hookErr) {
+		code = strconv.Itoa(hookErr.StatusCode)
+	}
+	responseLabels := metricLabels("code", code)
+	for k, v := range labels {
+		responseLabels[k] = v
+	}
+	metrics.inc(metricResponsesTotal, responseLabels)
+
+	return response, err
+}
+
+// evalTemplate evaluates a handler template, counting evaluation failures.
+func evalTemplate(name, template string, event *corev2.Event) (string, error) {
+	result, err := templates.EvalTemplate(name, template, event)
+	if err != nil {
+		metrics.inc(metricTemplateErrorsTotal, metricLabels("namespace", event.Namespace, "template", name))
+	}
+	return result, err
 }
 
 func getPagerDutyDedupKey(event *corev2.Event) (string, error) {
-	return templates.EvalTemplate("dedupKey", config.dedupKeyTemplate, event)
+	if dedupKeyStrategy(config.dedupKeyStrategy) == annotationDedupKeyStrategy {
+		if key, ok := annotationDedupKey(event); ok {
+			return key, nil
+		}
+	}
+
+	template := config.dedupKeyTemplate
+	if isKeepalive(event) && len(config.keepaliveDedupKey) > 0 {
+		template = config.keepaliveDedupKey
+	}
+	key, err := evalTemplate("dedupKey", template, event)
+	if err != nil {
+		return "", err
+	}
+	// the key is checked before it is hashed or extended, which would hide
+	// that it is empty
+	if len(strings.TrimSpace(key)) == 0 {
+		return "", errEmptyDedupKey
+	}
+
+	switch dedupKeyStrategy(config.dedupKeyStrategy) {
+	case hashDedupKeyStrategy:
+		return hashDedupKey(key), nil
+	case episodeDedupKeyStrategy:
+		return episodeDedupKey(key, event), nil
+	}
+	return key, nil
 }
 
 func getPagerDutySeverity(event *corev2.Event, statusMapJSON string) (string, error) {
@@ -535,7 +1472,7 @@ func getPagerDutySeverity(event *corev2.Event, statusMapJSON string) (string, er
 		status := event.Check.Status
 		severity := statusMap[status]
 		if len(severity) > 0 {
-			return severity, nil
+			return adjustSeverity(event, severity), nil
 		}
 	}
 
@@ -546,7 +1483,15 @@ func getPagerDutySeverity(event *corev2.Event, statusMapJSON string) (string, er
 		severity = severities[event.Check.Status]
 	}
 
-	return severity, nil
+	return adjustSeverity(event, severity), nil
+}
+
+// adjustSeverity escalates the severity of long failing checks, then caps it
+// outside of business hours.
+func adjustSeverity(event *corev2.Event, severity string) string {
+	now := time.Now()
+	severity = escalateSeverity(event, severity, now)
+	return applyBusinessHours(event, severity, now)
 }
 
 func parseStatusMap(statusMapJSON string) (map[uint32]string, error) {
@@ -573,40 +1518,33 @@ func parseStatusMap(statusMapJSON string) (map[uint32]string, error) {
 }
 
 func getSummary(event *corev2.Event) (string, error) {
-	summary, err := templates.EvalTemplate("summary", config.summaryTemplate, event)
+	template := config.summaryTemplate
+	if isKeepalive(event) && len(config.keepaliveSummary) > 0 {
+		template = config.keepaliveSummary
+	}
+	summary, err := evalTemplate("summary", template, event)
 	if err != nil {
-		return "", fmt.Errorf("failed to evaluate template %s: %v", config.summaryTemplate, err)
+		return "", fmt.Errorf("failed to evaluate template %s: %v", template, err)
 	}
 	// "The maximum permitted length of this property is 1024 characters."
 	if len(summary) > 1024 {
 		summary = summary[:1024]
+		metrics.inc(metricTruncationsTotal, metricLabels("namespace", event.Namespace, "field", "summary"))
 	}
-	log.Printf("Incident Summary: %s", summary)
+	eventLogger(event).Info("incident summary", "summary", summary)
 	return summary, nil
 }
 
-func getTimestamp(event *corev2.Event) string {
-	timestamp := ""
-	if config.useEventTimestamp {
-		sec := event.Timestamp / 1000
-		nsec := (event.Timestamp % 1000) * int64(time.Millisecond)
-		// Convert the Sensu event timestamp to a time.Time object
-		t := time.Unix(sec, nsec)
-		// Format the time in the desired layout
-		timestamp = t.Format("2006-01-02T15:04:05.000-0700")
-	}
-
-	return timestamp
-}
-
 func getGroup(event *corev2.Event) (string, error) {
 	var (
 		group string
 		err   error
 	)
 
-	if len(config.groupTemplate) > 0 {
-		group, err = templates.EvalTemplate("group", config.groupTemplate, event)
+	if isKeepalive(event) && len(config.keepaliveGroupBy) > 0 {
+		group = keepaliveGroup(event)
+	} else if len(config.groupTemplate) > 0 {
+		group, err = evalTemplate("group", config.groupTemplate, event)
 		if err != nil {
 			return "", fmt.Errorf("failued to evaluate template %s: %v", config.groupTemplate, err)
 		}
@@ -624,7 +1562,7 @@ func getComponent(event *corev2.Event) (string, error) {
 	)
 
 	if len(config.componentTemplate) > 0 {
-		component, err = templates.EvalTemplate("component", config.componentTemplate, event)
+		component, err = evalTemplate("component", config.componentTemplate, event)
 		if err != nil {
 			return "", fmt.Errorf("failued to evaluate template %s: %v", config.componentTemplate, err)
 		}
@@ -642,7 +1580,7 @@ func getClass(event *corev2.Event) (string, error) {
 	)
 
 	if len(config.classTemplate) > 0 {
-		class, err = templates.EvalTemplate("class", config.classTemplate, event)
+		class, err = evalTemplate("class", config.classTemplate, event)
 		if err != nil {
 			return "", fmt.Errorf("failued to evaluate template %s: %v", config.classTemplate, err)
 		}
@@ -654,8 +1592,14 @@ func getClass(event *corev2.Event) (string, error) {
 }
 
 func getDetails(event *corev2.Event) (details interface{}, err error) {
+	if config.detailsFormat == metricsDetailsFormat.String() {
+		return getMetricsDetails(event), nil
+	}
+	if config.detailsFormat == curatedDetailsFormat.String() {
+		return getCuratedDetails(event), nil
+	}
 	if len(config.detailsTemplate) > 0 {
-		detailsStr, err := templates.EvalTemplate("details", config.detailsTemplate, event)
+		detailsStr, err := evalTemplate("details", config.detailsTemplate, event)
 		if err != nil {
 			return "", fmt.Errorf("failed to evaluate template %s: %v", config.detailsTemplate, err)
 		}
@@ -663,14 +1607,14 @@ func getDetails(event *corev2.Event) (details interface{}, err error) {
 		details = detailsStr
 		if config.detailsFormat == jsonDetailsFormat.String() {
 			var msgMap interface{}
-			fmt.Println("preeval", config.detailsTemplate)
-			fmt.Println("details", detailsStr)
+			log := eventLogger(event)
+			log.Debug("evaluated details template", "template", config.detailsTemplate, "details", detailsStr)
 			err = json.Unmarshal([]byte(detailsStr), &msgMap)
 			if err != nil {
 				return "", fmt.Errorf("--details-template needs to be a valid json document: %v", err)
 			}
 			details = msgMap
-			fmt.Println("resolved", details)
+			log.Debug("resolved details", "details", details)
 		}
 	} else {
 		details = event
@@ -6,7 +6,6 @@ import (
 	"os"
 	"reflect"
 	"testing"
-	"time"
 
 	corev2 "github.com/sensu/core/v2"
 	"github.com/stretchr/testify/assert"
@@ -307,6 +306,44 @@ func Test_checkArgs(t *testing.T) {
 			wantErr:    true,
 			wantErrMsg: "invalid details format: invalidformat",
 		},
+		{
+			name: "error with invalid events api",
+			config: HandlerConfig{
+				detailsFormat: "json",
+				authToken:     "aaa",
+				eventsAPI:     "v3",
+			},
+			args: args{
+				event: corev2.FixtureEvent("foo", "bar"),
+			},
+			wantErr:    true,
+			wantErrMsg: "invalid events api: v3",
+		},
+		{
+			name: "error with invalid region",
+			config: HandlerConfig{
+				detailsFormat: "json",
+				authToken:     "aaa",
+				region:        "ap",
+			},
+			args: args{
+				event: corev2.FixtureEvent("foo", "bar"),
+			},
+			wantErr:    true,
+			wantErrMsg: "invalid region: ap",
+		},
+		{
+			name: "error with webhook output without url",
+			config: HandlerConfig{
+				detailsFormat: "json",
+				output:        "webhook",
+			},
+			args: args{
+				event: corev2.FixtureEvent("foo", "bar"),
+			},
+			wantErr:    true,
+			wantErrMsg: "--output webhook requires --webhook-url",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(
@@ -362,7 +399,7 @@ func Test_handleEventContactRouting(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				if err := handleEventContactRouting(tt.args.event); (err != nil) != tt.wantErr {
+				if _, err := handleEventContactRouting(tt.args.event); (err != nil) != tt.wantErr {
 					t.Errorf("handleEventContactRouting() error = %v, wantErr %v", err, tt.wantErr)
 				}
 			},
@@ -385,7 +422,7 @@ func Test_handleEventForContact(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				if err := handleEventForContact(tt.args.event, tt.args.contact); (err != nil) != tt.wantErr {
+				if _, err := handleEventForContact(tt.args.event, tt.args.contact); (err != nil) != tt.wantErr {
 					t.Errorf("handleEventForContact() error = %v, wantErr %v", err, tt.wantErr)
 				}
 			},
@@ -456,26 +493,38 @@ func Test_getTimestamp(t *testing.T) {
 		{
 			name:              "do not use event timestamp",
 			useEventTimestamp: false,
-			eventTimestamp:    1561246706000,
+			eventTimestamp:    1561246706,
 			want:              "",
 		},
 		{
-			name:              "use event timestamp",
+			name:              "use event timestamp in seconds",
 			useEventTimestamp: true,
-			eventTimestamp:    1561246706000,
-			want:              time.Unix(1561246706, 0).Format("2006-01-02T15:04:05.000-0700"),
+			eventTimestamp:    1561246706,
+			want:              "2019-06-22T23:38:26.000Z",
 		},
 		{
-			name:              "use event timestamp with ms precision",
+			name:              "use event timestamp in milliseconds",
 			useEventTimestamp: true,
 			eventTimestamp:    1561246706123,
-			want:              time.Unix(1561246706, 123*int64(time.Millisecond)).Format("2006-01-02T15:04:05.000-0700"),
+			want:              "2019-06-22T23:38:26.123Z",
+		},
+		{
+			name:              "use event timestamp in microseconds",
+			useEventTimestamp: true,
+			eventTimestamp:    1561246706123456,
+			want:              "2019-06-22T23:38:26.123Z",
+		},
+		{
+			name:              "use event timestamp in nanoseconds",
+			useEventTimestamp: true,
+			eventTimestamp:    1561246706123456789,
+			want:              "2019-06-22T23:38:26.123Z",
 		},
 		{
 			name:              "zero timestamp",
 			useEventTimestamp: true,
 			eventTimestamp:    0,
-			want:              time.Unix(0, 0).Format("2006-01-02T15:04:05.000-0700"),
+			want:              "",
 		},
 	}
 
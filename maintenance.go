@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev2 "github.com/sensu/core/v2"
+)
+
+type maintenanceAction string
+
+const (
+	dropMaintenanceAction  maintenanceAction = "drop"
+	infoMaintenanceAction  maintenanceAction = "info"
+	deferMaintenanceAction maintenanceAction = "defer"
+)
+
+func (ma maintenanceAction) IsValid() bool {
+	switch ma {
+	case dropMaintenanceAction, infoMaintenanceAction, deferMaintenanceAction:
+		return true
+	}
+	return false
+}
+
+// maintenanceTimeLayouts are the accepted layouts for the start and end of a
+// maintenance window. Layouts without an offset use the window time zone.
+var maintenanceTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04"}
+
+// maintenanceWindow is a period during which triggers for the matching events
+// are dropped, sent with the info severity or deferred. The window is either
+// a fixed date range or a recurring cron schedule with a duration.
+type maintenanceWindow struct {
+	Name     string            `json:"name"`
+	Start    string            `json:"start,omitempty"`
+	End      string            `json:"end,omitempty"`
+	Schedule string            `json:"schedule,omitempty"`
+	Duration string            `json:"duration,omitempty"`
+	Timezone string            `json:"timezone,omitempty"`
+	Match    eventMatcher      `json:"match"`
+	Action   maintenanceAction `json:"action"`
+
+	location *time.Location
+	start    time.Time
+	end      time.Time
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+// eventMatcher selects events by namespace, entity name, check name and
+// labels. Every field accepts shell patterns, empty fields match anything.
+type eventMatcher struct {
+	Namespace string            `json:"namespace,omitempty"`
+	Entity    string            `json:"entity,omitempty"`
+	Check     string            `json:"check,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+func (m eventMatcher) validate() error {
+	patterns := []string{m.Namespace, m.Entity, m.Check}
+	for _, pattern := range m.Labels {
+		patterns = append(patterns, pattern)
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+func (m eventMatcher) matches(event *corev2.Event) bool {
+	if !matchPattern(m.Namespace, event.Namespace) ||
+		!matchPattern(m.Entity, event.Entity.Name) ||
+		!matchPattern(m.Check, event.Check.Name) {
+		return false
+	}
+	for name, pattern := range m.Labels {
+		value, ok := event.Check.Labels[name]
+		if !ok {
+			value, ok = event.Entity.Labels[name]
+		}
+		if !ok || !matchPattern(pattern, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchPattern(pattern, value string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
+
+func loadMaintenanceWindows(filename string) ([]maintenanceWindow, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read maintenance file: %v", err)
+	}
+	var windows []maintenanceWindow
+	if err := json.Unmarshal(b, &windows); err != nil {
+		return nil, fmt.Errorf("failed to parse maintenance file: %v", err)
+	}
+	for i := range windows {
+		if err := windows[i].parse(); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: %v", windows[i].Name, err)
+		}
+	}
+	return windows, nil
+}
+
+func (w *maintenanceWindow) parse() error {
+	if !w.Action.IsValid() {
+		return fmt.Errorf("invalid action: %s", w.Action)
+	}
+	if err := w.Match.validate(); err != nil {
+		return err
+	}
+
+	location, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone: %s", w.Timezone)
+	}
+	w.location = location
+
+	if len(w.Schedule) > 0 {
+		if w.schedule, err = cron.ParseStandard(w.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule: %v", err)
+		}
+		if w.duration, err = time.ParseDuration(w.Duration); err != nil || w.duration <= 0 {
+			return fmt.Errorf("invalid duration: %s", w.Duration)
+		}
+		return nil
+	}
+
+	if w.start, err = parseMaintenanceTime(w.Start, location); err != nil {
+		return fmt.Errorf("invalid start: %s", w.Start)
+	}
+	if w.end, err = parseMaintenanceTime(w.End, location); err != nil {
+		return fmt.Errorf("invalid end: %s", w.End)
+	}
+	if !w.end.After(w.start) {
+		return fmt.Errorf("end must be after start")
+	}
+	return nil
+}
+
+func parseMaintenanceTime(value string, location *time.Location) (time.Time, error) {
+	var err error
+	for _, layout := range maintenanceTimeLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// isActive reports whether the window covers t. A recurring window is active
+// when the schedule fired during the window duration preceding t.
+func (w maintenanceWindow) isActive(t time.Time) bool {
+	if w.schedule != nil {
+		return !w.schedule.Next(t.In(w.location).Add(-w.duration)).After(t)
+	}
+	return !t.Before(w.start) && t.Before(w.end)
+}
+
+// activeMaintenanceWindow returns the first configured maintenance window
+// that is active and matches the event.
+func activeMaintenanceWindow(event *corev2.Event, t time.Time) (maintenanceWindow, bool) {
+	for _, window := range config.maintenanceWindows {
+		if window.isActive(t) && window.Match.matches(event) {
+			return window, true
+		}
+	}
+	return maintenanceWindow{}, false
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_maintenanceWindow_isActive(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+
+	fixed := maintenanceWindow{
+		Start:    "2024-08-01 22:00",
+		End:      "2024-08-02T02:00:00",
+		Timezone: "Europe/Paris",
+		Action:   dropMaintenanceAction,
+	}
+	require.NoError(t, fixed.parse())
+	assert.False(t, fixed.isActive(time.Date(2024, 8, 1, 21, 59, 0, 0, paris)))
+	assert.True(t, fixed.isActive(time.Date(2024, 8, 1, 22, 0, 0, 0, paris)))
+	assert.True(t, fixed.isActive(time.Date(2024, 8, 1, 23, 30, 0, 0, time.UTC)))
+	assert.False(t, fixed.isActive(time.Date(2024, 8, 2, 2, 0, 0, 0, paris)))
+
+	// every Saturday from 22:00 to 02:00
+	recurring := maintenanceWindow{
+		Schedule: "0 22 * * 6",
+		Duration: "4h",
+		Timezone: "Europe/Paris",
+		Action:   infoMaintenanceAction,
+	}
+	require.NoError(t, recurring.parse())
+	assert.False(t, recurring.isActive(time.Date(2024, 8, 3, 21, 0, 0, 0, paris)))
+	assert.True(t, recurring.isActive(time.Date(2024, 8, 3, 22, 0, 0, 0, paris)))
+	assert.True(t, recurring.isActive(time.Date(2024, 8, 4, 1, 59, 0, 0, paris)))
+	assert.False(t, recurring.isActive(time.Date(2024, 8, 4, 2, 1, 0, 0, paris)))
+	assert.False(t, recurring.isActive(time.Date(2024, 8, 7, 23, 0, 0, 0, paris)))
+}
+
+func Test_maintenanceWindow_parseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  maintenanceWindow
+		wantErr string
+	}{
+		{"invalid action", maintenanceWindow{Action: "snooze"}, "invalid action: snooze"},
+		{"invalid timezone", maintenanceWindow{Action: "drop", Timezone: "Mars/Olympus"}, "invalid timezone: Mars/Olympus"},
+		{"invalid schedule", maintenanceWindow{Action: "drop", Schedule: "every day", Duration: "1h"}, "invalid schedule: expected exactly 5 fields, found 2: [every day]"},
+		{"missing duration", maintenanceWindow{Action: "drop", Schedule: "0 22 * * *"}, "invalid duration: "},
+		{"invalid start", maintenanceWindow{Action: "drop", Start: "tomorrow"}, "invalid start: tomorrow"},
+		{"end before start", maintenanceWindow{Action: "drop", Start: "2024-08-02 00:00", End: "2024-08-01 00:00"}, "end must be after start"},
+		{"invalid pattern", maintenanceWindow{Action: "drop", Match: eventMatcher{Entity: "["}}, `invalid pattern "["`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, tt.window.parse(), tt.wantErr)
+		})
+	}
+}
+
+func Test_eventMatcher_matches(t *testing.T) {
+	event := corev2.FixtureEvent("db-01", "check-disk")
+	event.Entity.Labels = map[string]string{"tier": "database", "region": "eu"}
+	event.Check.Labels = map[string]string{"region": "us"}
+
+	assert.True(t, eventMatcher{}.matches(event))
+	assert.True(t, eventMatcher{Namespace: "default", Entity: "db-*", Check: "check-*"}.matches(event))
+	assert.False(t, eventMatcher{Entity: "web-*"}.matches(event))
+	assert.True(t, eventMatcher{Labels: map[string]string{"tier": "data*"}}.matches(event))
+	assert.False(t, eventMatcher{Labels: map[string]string{"owner": "*"}}.matches(event))
+	// check labels take precedence over entity labels
+	assert.True(t, eventMatcher{Labels: map[string]string{"region": "us"}}.matches(event))
+}
+
+func Test_manageIncident_maintenance(t *testing.T) {
+	restoreConfig(t)
+	server, sent := newTestEndpoint(t)
+
+	dir := t.TempDir()
+	maintenanceFile := filepath.Join(dir, "maintenance.json")
+	now := time.Now().UTC()
+	windows := []maintenanceWindow{
+		{
+			Name:   "databases",
+			Start:  now.Add(-time.Hour).Format(time.RFC3339),
+			End:    now.Add(time.Hour).Format(time.RFC3339),
+			Match:  eventMatcher{Entity: "db-*"},
+			Action: dropMaintenanceAction,
+		},
+		{
+			Name:   "web",
+			Start:  now.Add(-time.Hour).Format(time.RFC3339),
+			End:    now.Add(time.Hour).Format(time.RFC3339),
+			Match:  eventMatcher{Entity: "web-*"},
+			Action: infoMaintenanceAction,
+		},
+	}
+	b, err := json.Marshal(windows)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(maintenanceFile, b, 0600))
+
+	config = testConfig(server.URL)
+	config.authToken = "token"
+	config.maintenanceFile = maintenanceFile
+
+	db := corev2.FixtureEvent("db-01", "check-disk")
+	db.Check.Status = 2
+	require.NoError(t, checkArgs(db))
+	result, err := manageIncident(db, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, skippedStatus, result.Status)
+
+	// resolves are always sent
+	db.Check.Status = 0
+	result, err = manageIncident(db, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "success", result.Status)
+
+	web := corev2.FixtureEvent("web-01", "check-http")
+	web.Check.Status = 2
+	_, err = manageIncident(web, "token", "")
+	require.NoError(t, err)
+
+	require.Len(t, *sent, 2)
+	assert.Equal(t, "resolve", (*sent)[0]["event_action"])
+	assert.Equal(t, "trigger", (*sent)[1]["event_action"])
+	assert.Equal(t, "info", (*sent)[1]["payload"].(map[string]interface{})["severity"])
+
+	// deferring triggers requires a state file
+	windows[0].Action = deferMaintenanceAction
+	b, err = json.Marshal(windows)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(maintenanceFile, b, 0600))
+	assert.EqualError(t, checkArgs(db), `maintenance window "databases": the defer action requires --state-file`)
+}
+
+func Test_manageIncident_maintenanceDefer(t *testing.T) {
+	restoreConfig(t)
+	server, sent := newTestEndpoint(t)
+
+	dir := t.TempDir()
+	maintenanceFile := filepath.Join(dir, "maintenance.json")
+	writeWindow := func(start, end time.Time) {
+		b, err := json.Marshal([]maintenanceWindow{{
+			Name:   "databases",
+			Start:  start.Format(time.RFC3339),
+			End:    end.Format(time.RFC3339),
+			Action: deferMaintenanceAction,
+		}})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(maintenanceFile, b, 0600))
+	}
+	now := time.Now().UTC()
+
+	config = testConfig(server.URL)
+	config.authToken = "token"
+	config.stateFile = filepath.Join(dir, "state.json")
+	config.resendWindow = "1h"
+	config.maintenanceFile = maintenanceFile
+	db := corev2.FixtureEvent("db-01", "check-disk")
+
+	// the incident is triggered before the window
+	writeWindow(now.Add(time.Hour), now.Add(2*time.Hour))
+	db.Check.Status = 2
+	require.NoError(t, checkArgs(db))
+	_, err := manageIncident(db, "token", "")
+	require.NoError(t, err)
+	require.Len(t, *sent, 1)
+
+	// the severity change is deferred during the window
+	writeWindow(now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, checkArgs(db))
+	db.Check.Status = 1
+	result, err := manageIncident(db, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, skippedStatus, result.Status)
+	require.Len(t, *sent, 1)
+
+	// once the window is over, the deferred trigger is sent although it
+	// repeats the last trigger within the resend window
+	writeWindow(now.Add(-2*time.Hour), now.Add(-time.Hour))
+	require.NoError(t, checkArgs(db))
+	db.Check.Status = 2
+	result, err = manageIncident(db, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "success", result.Status)
+	require.Len(t, *sent, 2)
+	assert.Equal(t, "trigger", (*sent)[1]["event_action"])
+
+	// the next trigger is redundant again
+	result, err = manageIncident(db, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, skippedStatus, result.Status)
+	assert.Len(t, *sent, 2)
+}
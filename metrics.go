@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Handler outcome metrics, rendered in the Prometheus text exposition format.
+// Histograms are stored as their cumulative _bucket, _sum and _count series so
+// that every series is a plain counter that can be merged with the values
+// written by previous handler executions.
+const (
+	metricEventsTotal         = "sensu_pagerduty_handler_events_total"
+	metricResponsesTotal      = "sensu_pagerduty_handler_responses_total"
+	metricRetriesTotal        = "sensu_pagerduty_handler_retries_total"
+	metricFallbackTotal       = "sensu_pagerduty_handler_fallback_events_total"
+	metricTruncationsTotal    = "sensu_pagerduty_handler_truncations_total"
+	metricTemplateErrorsTotal = "sensu_pagerduty_handler_template_errors_total"
+	metricSendDuration        = "sensu_pagerduty_handler_send_duration_seconds"
+)
+
+type metricFamily struct {
+	name string
+	kind string
+	help string
+}
+
+var metricFamilies = []metricFamily{
+	{metricEventsTotal, "counter", "Events handled, by action and outcome."},
+	{metricResponsesTotal, "counter", "Responses received from PagerDuty, by HTTP status code."},
+	{metricRetriesTotal, "counter", "Additional send attempts made for an event."},
+	{metricFallbackTotal, "counter", "Fallback events sent after the original event was rejected."},
+	{metricTruncationsTotal, "counter", "Payload fields truncated to fit the PagerDuty limits."},
+	{metricTemplateErrorsTotal, "counter", "Template evaluation failures, by template."},
+	{metricSendDuration, "histogram", "Time spent sending events to PagerDuty."},
+}
+
+var sendDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// metrics holds the metrics collected during this handler execution.
+var metrics = newMetricsRegistry()
+
+type metricsRegistry struct {
+	mu     sync.Mutex
+	series map[string]float64
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{series: map[string]float64{}}
+}
+
+// metricLabels builds the label set for a metric, leaving out empty values.
+func metricLabels(kv ...string) map[string]string {
+	labels := make(map[string]string, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		if len(kv[i+1]) > 0 {
+			labels[kv[i]] = kv[i+1]
+		}
+	}
+	return labels
+}
+
+func (m *metricsRegistry) inc(name string, labels map[string]string) {
+	m.add(name, labels, 1)
+}
+
+func (m *metricsRegistry) add(name string, labels map[string]string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series[seriesKey(name, labels)] += value
+}
+
+func (m *metricsRegistry) observe(name string, labels map[string]string, d time.Duration) {
+	seconds := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bucketLabels := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		bucketLabels[k] = v
+	}
+	for _, bucket := range sendDurationBuckets {
+		// buckets are cumulative, every bucket is written even when empty
+		count := 0.0
+		if seconds <= bucket {
+			count = 1
+		}
+		bucketLabels["le"] = strconv.FormatFloat(bucket, 'f', -1, 64)
+		m.series[seriesKey(name+"_bucket", bucketLabels)] += count
+	}
+	bucketLabels["le"] = "+Inf"
+	m.series[seriesKey(name+"_bucket", bucketLabels)]++
+	m.series[seriesKey(name+"_sum", labels)] += seconds
+	m.series[seriesKey(name+"_count", labels)]++
+}
+
+// merge adds the series found in a previously written exposition to the
+// registry. Comments and unknown metrics are ignored.
+func (m *metricsRegistry) merge(r io.Reader) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		if i < 0 {
+			return fmt.Errorf("invalid metric line: %s", line)
+		}
+		key := line[:i]
+		if _, ok := familyOf(key); !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			return fmt.Errorf("invalid metric line: %s", line)
+		}
+		m.series[key] += value
+	}
+	return scanner.Err()
+}
+
+// WriteTo writes all the series in the Prometheus text exposition format.
+func (m *metricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byFamily := map[string][]string{}
+	for key := range m.series {
+		family, _ := familyOf(key)
+		byFamily[family.name] = append(byFamily[family.name], key)
+	}
+
+	var buf bytes.Buffer
+	for _, family := range metricFamilies {
+		keys := byFamily[family.name]
+		if len(keys) == 0 {
+			continue
+		}
+		sort.Slice(keys, func(i, j int) bool { return seriesLess(keys[i], keys[j]) })
+		fmt.Fprintf(&buf, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", family.name, family.kind)
+		for _, key := range keys {
+			fmt.Fprintf(&buf, "%s %s\n", key, strconv.FormatFloat(m.series[key], 'g', -1, 64))
+		}
+	}
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// writeMetricsTextfile adds the metrics of this execution to the counters
+// already present in path, for consumption by the node_exporter textfile
+// collector.
+func writeMetricsTextfile(path string) error {
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	registry := newMetricsRegistry()
+	f, err := os.Open(path)
+	if err == nil {
+		err = registry.merge(f)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read metrics textfile: %v", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	metrics.mu.Lock()
+	for key, value := range metrics.series {
+		registry.series[key] += value
+	}
+	metrics.mu.Unlock()
+
+	var buf bytes.Buffer
+	if _, err := registry.WriteTo(&buf); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), 0644)
+}
+
+// flushMetrics writes the collected metrics when a textfile is configured.
+// Failing to write metrics never fails the handler.
+func flushMetrics() {
+	if len(config.metricsTextfile) == 0 {
+		return
+	}
+	if err := writeMetricsTextfile(config.metricsTextfile); err != nil {
+		logger.Warn("failed to write metrics textfile", "error", err)
+	}
+}
+
+// maskRoutingKey hides all but the last four characters of a routing key so
+// that it can be used as a metric label.
+func maskRoutingKey(key string) string {
+	if len(key) <= 4 {
+		return strings.Repeat("*", len(key))
+	}
+	return "****" + key[len(key)-4:]
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func seriesKey(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, k := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, labelValueEscaper.Replace(labels[k])))
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func familyOf(key string) (metricFamily, bool) {
+	name := key
+	if i := strings.Index(key, "{"); i >= 0 {
+		name = key[:i]
+	}
+	for _, family := range metricFamilies {
+		switch name {
+		case family.name, family.name + "_bucket", family.name + "_sum", family.name + "_count":
+			return family, true
+		}
+	}
+	return metricFamily{}, false
+}
+
+// seriesLess orders series by name and labels, keeping histogram buckets in
+// ascending order of their upper bound.
+func seriesLess(a, b string) bool {
+	aBase, aLe := splitLe(a)
+	bBase, bLe := splitLe(b)
+	if aBase != bBase {
+		return aBase < bBase
+	}
+	return aLe < bLe
+}
+
+func splitLe(key string) (string, float64) {
+	i := strings.Index(key, `le="`)
+	if i < 0 {
+		return key, 0
+	}
+	j := strings.Index(key[i+4:], `"`)
+	if j < 0 {
+		return key, 0
+	}
+	le, err := strconv.ParseFloat(key[i+4:i+4+j], 64)
+	if err != nil {
+		return key, 0
+	}
+	return key[:i] + key[i+4+j:], le
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_metricsRegistry_WriteTo(t *testing.T) {
+	registry := newMetricsRegistry()
+	labels := metricLabels("namespace", "default", "destination", maskRoutingKey("abcdefgh"), "empty", "")
+	registry.inc(metricFallbackTotal, labels)
+	registry.inc(metricFallbackTotal, labels)
+	registry.observe(metricSendDuration, labels, 300*time.Millisecond)
+
+	var buf strings.Builder
+	_, err := registry.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, `# HELP sensu_pagerduty_handler_fallback_events_total Fallback events sent after the original event was rejected.
+# TYPE sensu_pagerduty_handler_fallback_events_total counter
+sensu_pagerduty_handler_fallback_events_total{destination="****efgh",namespace="default"} 2
+# HELP sensu_pagerduty_handler_send_duration_seconds Time spent sending events to PagerDuty.
+# TYPE sensu_pagerduty_handler_send_duration_seconds histogram
+sensu_pagerduty_handler_send_duration_seconds_bucket{destination="****efgh",le="0.05",namespace="default"} 0
+sensu_pagerduty_handler_send_duration_seconds_bucket{destination="****efgh",le="0.1",namespace="default"} 0
+sensu_pagerduty_handler_send_duration_seconds_bucket{destination="****efgh",le="0.25",namespace="default"} 0
+sensu_pagerduty_handler_send_duration_seconds_bucket{destination="****efgh",le="0.5",namespace="default"} 1
+sensu_pagerduty_handler_send_duration_seconds_bucket{destination="****efgh",le="1",namespace="default"} 1
+sensu_pagerduty_handler_send_duration_seconds_bucket{destination="****efgh",le="2.5",namespace="default"} 1
+sensu_pagerduty_handler_send_duration_seconds_bucket{destination="****efgh",le="5",namespace="default"} 1
+sensu_pagerduty_handler_send_duration_seconds_bucket{destination="****efgh",le="10",namespace="default"} 1
+sensu_pagerduty_handler_send_duration_seconds_bucket{destination="****efgh",le="30",namespace="default"} 1
+sensu_pagerduty_handler_send_duration_seconds_bucket{destination="****efgh",le="+Inf",namespace="default"} 1
+sensu_pagerduty_handler_send_duration_seconds_count{destination="****efgh",namespace="default"} 1
+sensu_pagerduty_handler_send_duration_seconds_sum{destination="****efgh",namespace="default"} 0.3
+`, buf.String())
+}
+
+func Test_writeMetricsTextfile(t *testing.T) {
+	originalMetrics := metrics
+	defer func() { metrics = originalMetrics }()
+
+	path := filepath.Join(t.TempDir(), "pagerduty.prom")
+	labels := metricLabels("namespace", "default", "template", "summary")
+
+	for i := 0; i < 2; i++ {
+		metrics = newMetricsRegistry()
+		metrics.inc(metricTemplateErrorsTotal, labels)
+		require.NoError(t, writeMetricsTextfile(path))
+	}
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `sensu_pagerduty_handler_template_errors_total{namespace="default",template="summary"} 2`)
+	_, err = os.Stat(path + ".lock")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_maskRoutingKey(t *testing.T) {
+	assert.Equal(t, "****6789", maskRoutingKey("0123456789"))
+	assert.Equal(t, "***", maskRoutingKey("abc"))
+	assert.Equal(t, "", maskRoutingKey(""))
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// metricThreshold keeps the metric points whose name matches Name and whose
+// value crosses Value.
+type metricThreshold struct {
+	Name     string
+	Operator string
+	Value    float64
+}
+
+// metricThresholdOperators are tried in order, longest first so that ">="
+// isn't read as ">".
+var metricThresholdOperators = []string{">=", "<=", ">", "<"}
+
+// parseMetricThresholds parses a comma-separated list of thresholds written as
+// a name pattern, an operator and a value, e.g. "cpu.*>90,disk_free<10".
+func parseMetricThresholds(value string) ([]metricThreshold, error) {
+	var thresholds []metricThreshold
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		threshold, err := parseMetricThreshold(item)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, nil
+}
+
+func parseMetricThreshold(item string) (metricThreshold, error) {
+	for _, operator := range metricThresholdOperators {
+		name, value, ok := strings.Cut(item, operator)
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || len(name) == 0 {
+			break
+		}
+		if _, err := path.Match(name, ""); err != nil {
+			break
+		}
+		return metricThreshold{Name: name, Operator: operator, Value: f}, nil
+	}
+	return metricThreshold{}, fmt.Errorf("invalid metric threshold: %s", item)
+}
+
+func (t metricThreshold) crossed(point *corev2.MetricPoint) bool {
+	if !matchPattern(t.Name, point.Name) {
+		return false
+	}
+	switch t.Operator {
+	case ">=":
+		return point.Value >= t.Value
+	case "<=":
+		return point.Value <= t.Value
+	case ">":
+		return point.Value > t.Value
+	case "<":
+		return point.Value < t.Value
+	}
+	return false
+}
+
+// metricPoints returns the metric points of the event kept by the metrics
+// filter and thresholds. When thresholds are configured, only the points
+// crossing one of them are kept.
+func metricPoints(event *corev2.Event) []*corev2.MetricPoint {
+	if event.Metrics == nil {
+		return nil
+	}
+	var points []*corev2.MetricPoint
+	for _, point := range event.Metrics.Points {
+		if point == nil {
+			continue
+		}
+		if config.metricsRegexp != nil && !config.metricsRegexp.MatchString(point.Name) {
+			continue
+		}
+		if len(config.thresholds) > 0 && !anyThresholdCrossed(point) {
+			continue
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+func anyThresholdCrossed(point *corev2.MetricPoint) bool {
+	for _, threshold := range config.thresholds {
+		if threshold.crossed(point) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatMetricPoints renders the metric points as a table with one point per
+// line.
+func formatMetricPoints(points []*corev2.MetricPoint) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tVALUE\tTAGS\tTIMESTAMP")
+	for _, point := range points {
+		tags := make([]string, 0, len(point.Tags))
+		for _, tag := range point.Tags {
+			if tag == nil {
+				continue
+			}
+			tags = append(tags, tag.Name+"="+tag.Value)
+		}
+		timestamp := ""
+		if point.Timestamp > 0 {
+			timestamp = unixTime(point.Timestamp).UTC().Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			point.Name, strconv.FormatFloat(point.Value, 'g', -1, 64), strings.Join(tags, ","), timestamp)
+	}
+	_ = w.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// getMetricsDetails builds the details of an event from its metric points, so
+// that the incident shows the offending values.
+func getMetricsDetails(event *corev2.Event) map[string]interface{} {
+	points := metricPoints(event)
+	details := map[string]interface{}{
+		"check_output":  event.Check.Output,
+		"metric_points": len(points),
+	}
+	if len(points) > 0 {
+		details["metrics"] = formatMetricPoints(points)
+	}
+	return details
+}
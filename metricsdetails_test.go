@@ -0,0 +1,84 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func metricsFixtureEvent() *corev2.Event {
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Output = "cpu high"
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{
+		{Name: "cpu.user", Value: 95.5, Timestamp: 1700000000, Tags: []*corev2.MetricTag{{Name: "cpu", Value: "0"}}},
+		{Name: "cpu.system", Value: 2, Timestamp: 1700000000000},
+		{Name: "disk_free", Value: 5},
+	}}
+	return event
+}
+
+func Test_parseMetricThresholds(t *testing.T) {
+	thresholds, err := parseMetricThresholds("cpu.*>=90, disk_free<10")
+	require.NoError(t, err)
+	assert.Equal(t, []metricThreshold{
+		{Name: "cpu.*", Operator: ">=", Value: 90},
+		{Name: "disk_free", Operator: "<", Value: 10},
+	}, thresholds)
+
+	_, err = parseMetricThresholds("cpu.user")
+	assert.EqualError(t, err, "invalid metric threshold: cpu.user")
+	_, err = parseMetricThresholds("cpu.user>high")
+	assert.EqualError(t, err, "invalid metric threshold: cpu.user>high")
+	_, err = parseMetricThresholds("[>1")
+	assert.EqualError(t, err, "invalid metric threshold: [>1")
+}
+
+func Test_metricPoints(t *testing.T) {
+	restoreConfig(t)
+
+	event := metricsFixtureEvent()
+	assert.Len(t, metricPoints(event), 3)
+
+	config.metricsRegexp = regexp.MustCompile(`^cpu\.`)
+	assert.Len(t, metricPoints(event), 2)
+
+	thresholds, err := parseMetricThresholds("cpu.*>90,disk_free<10")
+	require.NoError(t, err)
+	config.thresholds = thresholds
+	points := metricPoints(event)
+	require.Len(t, points, 1)
+	assert.Equal(t, "cpu.user", points[0].Name)
+
+	config.metricsRegexp = nil
+	assert.Len(t, metricPoints(event), 2)
+
+	event.Metrics = nil
+	assert.Empty(t, metricPoints(event))
+}
+
+func Test_GetDetailsMetrics(t *testing.T) {
+	restoreConfig(t)
+
+	config.detailsFormat = "metrics"
+	config.detailsTemplate = ""
+	event := metricsFixtureEvent()
+
+	details, err := getDetails(event)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"check_output":  "cpu high",
+		"metric_points": 3,
+		"metrics": "NAME        VALUE  TAGS   TIMESTAMP\n" +
+			"cpu.user    95.5   cpu=0  2023-11-14T22:13:20Z\n" +
+			"cpu.system  2             2023-11-14T22:13:20Z\n" +
+			"disk_free   5",
+	}, details)
+
+	config.metricsRegexp = regexp.MustCompile(`^mem`)
+	details, err = getDetails(event)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"check_output": "cpu high", "metric_points": 0}, details)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"unicode/utf8"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+)
+
+// notedStatus is the status reported for events added as a note to an open
+// incident instead of being sent.
+const notedStatus = "noted"
+
+// maxNoteLength is the longest incident note accepted by PagerDuty.
+const maxNoteLength = 25000
+
+// openIncidentStatuses are the statuses of the incidents that aren't
+// resolved.
+var openIncidentStatuses = []string{"triggered", "acknowledged"}
+
+func newRESTClient() *pagerduty.RESTClient {
+	client := pagerduty.NewRESTClient(config.apiToken)
+	if len(config.region) > 0 {
+		client.Region(pagerduty.Region(config.region))
+	}
+	if len(config.apiEndpoint) > 0 {
+		client.AlternateEndpoint(config.apiEndpoint)
+	}
+	if len(config.apiFrom) > 0 {
+		client.From(config.apiFrom)
+	}
+	return client
+}
+
+// findOpenIncident returns the open incident with the deduplication key, if
+// any.
+func findOpenIncident(ctx context.Context, client *pagerduty.RESTClient, dedupKey string) (*pagerduty.Incident, error) {
+	incidents, err := client.ListIncidents(ctx, pagerduty.ListIncidentsOptions{
+		IncidentKey: dedupKey,
+		Statuses:    openIncidentStatuses,
+		Limit:       1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incidents: %v", err)
+	}
+	if len(incidents) == 0 {
+		return nil, nil
+	}
+	return &incidents[0], nil
+}
+
+// incidentNote describes the latest occurrence of the event.
+func incidentNote(event *corev2.Event) string {
+	note := fmt.Sprintf(
+		"%s/%s is still failing with status %d (occurrence %d):\n\n",
+		event.Entity.Name, event.Check.Name, event.Check.Status, event.Check.Occurrences,
+	)
+	output := event.Check.Output
+	// secrets are redacted before truncating, which could cut them short of
+	// matching the patterns
+	if config.redact {
+		redactor := newRedactor(event)
+		note = redactor.redactString(note)
+		output = redactor.redactString(output)
+	}
+	if len(note)+len(output) > maxNoteLength {
+		output = truncateString(output, maxNoteLength-len(note)-3) + "..."
+	}
+	// the header alone can be too long with very long entity or check names
+	return truncateString(note+output, maxNoteLength)
+}
+
+// truncateString returns the first n bytes of s at most, without splitting a
+// UTF-8 encoded character.
+func truncateString(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// addIncidentNote adds the latest check output as a note to the open
+// incident with the deduplication key. It reports false when there is no
+// open incident to add the note to.
+func addIncidentNote(ctx context.Context, log *slog.Logger, event *corev2.Event, dedupKey string) (bool, error) {
+	client := newRESTClient()
+	incident, err := findOpenIncident(ctx, client, dedupKey)
+	if err != nil || incident == nil {
+		return false, err
+	}
+	if _, err := client.CreateIncidentNote(ctx, incident.ID, incidentNote(event)); err != nil {
+		return false, fmt.Errorf("failed to add incident note: %v", err)
+	}
+	log.Info("incident note added", "incident_id", incident.ID)
+	return true, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+)
+
+// outputDriver delivers the events built by the handler to their receiver.
+// Events are always built as PagerDuty v2 events, so that every driver gets
+// the same summary, severity, details and deduplication key.
+type outputDriver interface {
+	send(ctx context.Context, event *pagerduty.V2Event) (*pagerduty.V2EventResponse, error)
+}
+
+// outputType is the driver the events are delivered with.
+type outputType string
+
+const (
+	pagerDutyOutput outputType = "pagerduty"
+	webhookOutput   outputType = "webhook"
+)
+
+func (o outputType) IsValid() bool {
+	switch o {
+	case pagerDutyOutput, webhookOutput:
+		return true
+	}
+	return false
+}
+
+// pagerDutyDriver sends the events to the PagerDuty events API.
+type pagerDutyDriver struct {
+	client *pagerduty.Client
+}
+
+func (d pagerDutyDriver) send(ctx context.Context, event *pagerduty.V2Event) (*pagerduty.V2EventResponse, error) {
+	return postEvent(ctx, d.client, event)
+}
+
+// newDriver returns the driver of the configured output.
+func newDriver() outputDriver {
+	if outputType(config.output) == webhookOutput {
+		return newWebhookDriver()
+	}
+	return pagerDutyDriver{client: newClient()}
+}
@@ -0,0 +1,65 @@
+package pagerduty
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+)
+
+// SignatureHeader is the header of the HMAC-SHA256 signature of the request
+// body, as sha256=<hex digest>.
+const SignatureHeader = "X-Signature-256"
+
+// RequestAuth authenticates the requests sent to endpoints other than
+// PagerDuty, such as relays which require an auth header.
+type RequestAuth struct {
+	// Headers are added to the requests as is.
+	Headers map[string]string
+	// BearerToken is sent in the Authorization header.
+	BearerToken string
+	// HMACSecret signs the request body in the SignatureHeader header.
+	HMACSecret string
+}
+
+// Signature returns the HMAC-SHA256 signature of the body.
+func Signature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Apply sets the authentication headers of the request with the body.
+func (a RequestAuth) Apply(req *http.Request, body []byte) {
+	for name, value := range a.Headers {
+		req.Header.Set(name, value)
+	}
+	if len(a.BearerToken) > 0 {
+		req.Header.Set("Authorization", "Bearer "+a.BearerToken)
+	}
+	if len(a.HMACSecret) > 0 {
+		req.Header.Set(SignatureHeader, Signature(a.HMACSecret, body))
+	}
+}
+
+// Auth sets the authentication of the requests sent to the alternate and
+// failover endpoints. It is never sent to the PagerDuty endpoints.
+func (c *Client) Auth(auth RequestAuth) {
+	c.auth = auth
+}
+
+// isPagerDutyEndpoint reports whether the endpoint is the events API of a
+// PagerDuty service region.
+func isPagerDutyEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	for _, hosts := range regions {
+		if u.Hostname() == hosts.events {
+			return true
+		}
+	}
+	return false
+}
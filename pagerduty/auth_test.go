@@ -0,0 +1,43 @@
+package pagerduty
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature(t *testing.T) {
+	assert.Equal(t,
+		"sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		Signature("key", []byte("The quick brown fox jumps over the lazy dog")),
+	)
+}
+
+func TestRequestAuth_Apply(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/v2/enqueue", nil)
+	require.NoError(t, err)
+	body := []byte(`{"event_action":"trigger"}`)
+	RequestAuth{
+		Headers:     map[string]string{"X-Relay": "sensu"},
+		BearerToken: "relay-token",
+		HMACSecret:  "relay-secret",
+	}.Apply(req, body)
+	assert.Equal(t, "sensu", req.Header.Get("X-Relay"))
+	assert.Equal(t, "Bearer relay-token", req.Header.Get("Authorization"))
+	assert.Equal(t, Signature("relay-secret", body), req.Header.Get(SignatureHeader))
+
+	req, err = http.NewRequest(http.MethodPost, "http://localhost:8080/v2/enqueue", nil)
+	require.NoError(t, err)
+	RequestAuth{}.Apply(req, body)
+	assert.Empty(t, req.Header)
+}
+
+func Test_isPagerDutyEndpoint(t *testing.T) {
+	assert.True(t, isPagerDutyEndpoint("https://events.pagerduty.com/v2/enqueue"))
+	assert.True(t, isPagerDutyEndpoint("https://events.eu.pagerduty.com/v2/enqueue"))
+	assert.False(t, isPagerDutyEndpoint("http://localhost:8080/v2/enqueue"))
+	assert.False(t, isPagerDutyEndpoint("https://events.pagerduty.com.example.com/v2/enqueue"))
+	assert.False(t, isPagerDutyEndpoint("://invalid"))
+}
@@ -0,0 +1,114 @@
+package pagerduty
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// PagerDuty utilities to access the generic PagerDuty events API v1, for the
+// PagerDuty agents and relays which don't accept v2 events.
+
+// V1Event is an event of the generic events API v1.
+type V1Event struct {
+	ServiceKey  string      `json:"service_key"`
+	EventType   string      `json:"event_type"`
+	IncidentKey string      `json:"incident_key,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Details     interface{} `json:"details,omitempty"`
+	Client      string      `json:"client,omitempty"`
+	ClientURL   string      `json:"client_url,omitempty"`
+	Contexts    []V1Context `json:"contexts,omitempty"`
+}
+
+// V1Context is a link or an image attached to a v1 event.
+type V1Context struct {
+	Type string `json:"type"`
+	Href string `json:"href,omitempty"`
+	Text string `json:"text,omitempty"`
+	Src  string `json:"src,omitempty"`
+	Alt  string `json:"alt,omitempty"`
+}
+
+// V1EventResponse is the json response body for a v1 event
+type V1EventResponse struct {
+	Status      string   `json:"status,omitempty"`
+	IncidentKey string   `json:"incident_key,omitempty"`
+	Message     string   `json:"message,omitempty"`
+	Errors      []string `json:"errors,omitempty"`
+
+	// StatusCode is the HTTP response status code.
+	StatusCode int `json:"-"`
+	// Endpoint is the endpoint which accepted the event.
+	Endpoint string `json:"-"`
+}
+
+const v1EventsAPIPath = "/generic/2010-04-15/create_event.json"
+
+// NewV1Client returns a client for the events API v1 of the US service
+// region.
+func NewV1Client() *Client {
+	return newClient(v1EventsAPIPath)
+}
+
+// NewV1Event maps a v2 event onto the v1 schema: the routing key becomes the
+// service key, the deduplication key the incident key, the summary the
+// description and the custom details the details. The severity, source,
+// component, group, class and timestamp of the payload have no v1 equivalent.
+func NewV1Event(e *V2Event) *V1Event {
+	event := &V1Event{
+		ServiceKey:  e.RoutingKey,
+		EventType:   e.Action,
+		IncidentKey: e.DedupKey,
+		Client:      e.Client,
+		ClientURL:   e.ClientURL,
+	}
+	if e.Payload != nil {
+		event.Description = e.Payload.Summary
+		event.Details = e.Payload.Details
+	}
+	event.Contexts = append(v1Contexts("link", e.Links), v1Contexts("image", e.Images)...)
+	return event
+}
+
+// v1Contexts converts v2 links or images, whose fields are the same as the
+// v1 contexts, to v1 contexts of the given type.
+func v1Contexts(contextType string, values []interface{}) []V1Context {
+	var contexts []V1Context
+	for _, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		var item V1Context
+		if err := json.Unmarshal(data, &item); err != nil {
+			continue
+		}
+		item.Type = contextType
+		contexts = append(contexts, item)
+	}
+	return contexts
+}
+
+// ManageV1EventWithContext sends a v1 event. As for v2 events, non-JSON
+// responses are only accepted without strict response parsing.
+func (c *Client) ManageV1EventWithContext(ctx context.Context, e *V1Event) (*V1EventResponse, error) {
+	resp, bodyContent, endpoint, err := c.postEvent(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+
+	var eventResponse V1EventResponse
+	if err := json.Unmarshal(bodyContent, &eventResponse); err != nil {
+		if c.strictResponses(endpoint) {
+			return nil, err
+		}
+
+		eventResponse.Status = resp.Status
+		eventResponse.IncidentKey = e.IncidentKey
+		eventResponse.Message = string(bodyContent)
+	}
+	eventResponse.StatusCode = resp.StatusCode
+	eventResponse.Endpoint = endpoint
+
+	return &eventResponse, nil
+}
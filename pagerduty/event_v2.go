@@ -45,6 +45,11 @@ type V2EventResponse struct {
 	DedupKey string   `json:"dedup_key,omitempty"`
 	Message  string   `json:"message,omitempty"`
 	Errors   []string `json:"errors,omitempty"`
+
+	// StatusCode is the HTTP response status code.
+	StatusCode int `json:"-"`
+	// Endpoint is the endpoint which accepted the event.
+	Endpoint string `json:"-"`
 }
 
 // EventsAPIV2Error represents the error response received when an Events API V2 call fails. The
@@ -118,48 +123,121 @@ type EventsAPIV2ErrorObject struct {
 	Errors []string `json:"errors,omitempty"`
 }
 
-const v2EventsAPIEndpoint = "https://events.pagerduty.com/v2/enqueue"
+const v2EventsAPIPath = "/v2/enqueue"
 const version = "2.5.0"
 
+// UserAgent is the User-Agent header of the requests sent by the handler.
+const UserAgent = "sensu-pagerduty-handler/" + version
+
 type Client struct {
 	endpoint string
+	failover []string
+	path     string
+	strict   *bool
+	health   *EndpointHealth
+	auth     RequestAuth
 }
 
+// NewClient returns a client for the events API v2 of the US service region.
 func NewClient() *Client {
-	return &Client{endpoint: v2EventsAPIEndpoint}
+	return newClient(v2EventsAPIPath)
+}
+
+func newClient(path string) *Client {
+	return &Client{
+		endpoint: "https://" + regions[RegionUS].events + path,
+		path:     path,
+		health:   NewEndpointHealth(defaultEndpointCooldown),
+	}
+}
+
+// Region sends the events to the events API of the service region. Unknown
+// regions are ignored.
+func (c *Client) Region(region Region) {
+	if hosts, ok := regions[region]; ok {
+		c.endpoint = "https://" + hosts.events + c.path
+	}
 }
 
+// AlternateEndpoint sends the events to another endpoint, such as a PagerDuty
+// agent.
 func (c *Client) AlternateEndpoint(alternateEndpoint string) {
 	c.endpoint = alternateEndpoint
 }
 
+// StrictResponses sets whether accepted responses must be PagerDuty JSON
+// responses, whatever the endpoint. When false, other responses, as returned
+// by some PagerDuty agents, are reported with their HTTP status and body as
+// the message. By default, the responses of the PagerDuty endpoints are
+// parsed strictly and those of the other endpoints leniently.
+func (c *Client) StrictResponses(strict bool) {
+	c.strict = &strict
+}
+
+// strictResponses reports whether the responses of the endpoint are parsed
+// strictly, which is decided per endpoint as the events can fail over from
+// PagerDuty to an agent or the other way around.
+func (c *Client) strictResponses(endpoint string) bool {
+	if c.strict != nil {
+		return *c.strict
+	}
+	return isPagerDutyEndpoint(endpoint)
+}
+
 // ManageEventWithContext handles the trigger, acknowledge, and resolve methods for an event.
-// When connecting directly to the PagerDuty events API the response is returned as is. When
-// using a proxy a response is artificially built using the status code and returned data.
+// With strict response parsing the response is returned as is. Otherwise a response is
+// artificially built using the status code and returned data when it isn't JSON. Response
+// parsing is decided for the endpoint which accepted the event.
 func (c *Client) ManageEventWithContext(ctx context.Context, e *V2Event) (*V2EventResponse, error) {
-	data, err := json.Marshal(e)
+	resp, bodyContent, endpoint, err := c.postEvent(ctx, e)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewBuffer(data))
+	var eventResponse V2EventResponse
+	if err := json.Unmarshal(bodyContent, &eventResponse); err != nil {
+		if c.strictResponses(endpoint) {
+			return nil, err
+		}
+
+		// Some PD agents return non-JSON content. Read the response and set it in the message.
+		eventResponse.Status = resp.Status
+		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			eventResponse.DedupKey = e.DedupKey
+		}
+		eventResponse.Message = string(bodyContent)
+	}
+	eventResponse.StatusCode = resp.StatusCode
+	eventResponse.Endpoint = endpoint
+
+	return &eventResponse, nil
+}
+
+// post sends an encoded event to an endpoint and returns the response and its
+// body when the event was accepted. Error responses are returned as an
+// EventsAPIV2Error, the error object of both API versions being the same.
+func (c *Client) post(ctx context.Context, endpoint string, data []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(data))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "sensu-pagerduty-handler/"+version)
+	req.Header.Set("User-Agent", UserAgent)
 	req.Header.Set("Content-Type", "application/json")
+	if !isPagerDutyEndpoint(endpoint) {
+		c.auth.Apply(req, data)
+	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	defer func() { _ = resp.Body.Close() }() // explicitly discard error
 	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
 		errResp, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return nil, EventsAPIV2Error{
+			return nil, nil, EventsAPIV2Error{
 				StatusCode: resp.StatusCode,
 				message:    fmt.Sprintf("HTTP response with status code: %d: error: %s", resp.StatusCode, err),
 			}
@@ -172,32 +250,18 @@ func (c *Client) ManageEventWithContext(ctx context.Context, e *V2Event) (*V2Eve
 				StatusCode: resp.StatusCode,
 				message:    fmt.Sprintf("HTTP response with status code: %d, JSON unmarshal object body failed: %s, body: %s", resp.StatusCode, err, string(errResp)),
 			}
-			return nil, eae
+			return nil, nil, eae
 		}
 
 		eae.StatusCode = resp.StatusCode
-		return nil, eae
+		return nil, nil, eae
 	}
 
-	var eventResponse V2EventResponse
 	bodyContent, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
-	}
-	if err := json.Unmarshal(bodyContent, &eventResponse); err != nil {
-		if c.endpoint == v2EventsAPIEndpoint {
-			return nil, err
-		}
-
-		// Some PD agents return non-JSON content. Read the response and set it in the message.
-		eventResponse.Status = resp.Status
-		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
-			eventResponse.DedupKey = e.DedupKey
-		}
-		eventResponse.Message = string(bodyContent)
+		return nil, nil, err
 	}
-
-	return &eventResponse, nil
+	return resp, bodyContent, nil
 }
 
 func apiErrorsDetailString(errs []string) string {
@@ -0,0 +1,110 @@
+package pagerduty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultEndpointCooldown is how long an endpoint which failed is tried after
+// the healthy endpoints.
+const defaultEndpointCooldown = time.Minute
+
+// EndpointHealth tracks the endpoints which recently failed, so that they are
+// tried after the healthy ones. It can be shared by several clients.
+type EndpointHealth struct {
+	mu       sync.Mutex
+	cooldown time.Duration
+	failed   map[string]time.Time
+}
+
+// NewEndpointHealth returns an EndpointHealth considering failed endpoints
+// unhealthy for the cooldown.
+func NewEndpointHealth(cooldown time.Duration) *EndpointHealth {
+	return &EndpointHealth{cooldown: cooldown, failed: map[string]time.Time{}}
+}
+
+// healthy reports whether the endpoint didn't fail within the cooldown.
+func (h *EndpointHealth) healthy(endpoint string, now time.Time) bool {
+	failed, ok := h.failed[endpoint]
+	return !ok || now.Sub(failed) >= h.cooldown
+}
+
+// order returns the endpoints with the healthy ones first, keeping their
+// order otherwise.
+func (h *EndpointHealth) order(endpoints []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	ordered := append([]string(nil), endpoints...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return h.healthy(ordered[i], now) && !h.healthy(ordered[j], now)
+	})
+	return ordered
+}
+
+func (h *EndpointHealth) record(endpoint string, healthy bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if healthy {
+		delete(h.failed, endpoint)
+	} else {
+		h.failed[endpoint] = time.Now()
+	}
+}
+
+// FailoverEndpoints sets the endpoints tried in order when the primary
+// endpoint, the endpoint of the region or the alternate endpoint, can't be
+// reached or returns a server error.
+func (c *Client) FailoverEndpoints(endpoints ...string) {
+	c.failover = endpoints
+}
+
+// Health shares the health of the endpoints with other clients.
+func (c *Client) Health(health *EndpointHealth) {
+	c.health = health
+}
+
+// shouldFailover reports whether the event can be sent to the next endpoint
+// after the error: connection errors and server errors.
+func shouldFailover(err error) bool {
+	var apiErr EventsAPIV2Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+// postEvent sends an event to the healthy endpoints first, failing over to
+// the next endpoint on connection errors and server errors. It returns the
+// response of the endpoint which accepted the event.
+func (c *Client) postEvent(ctx context.Context, e interface{}) (*http.Response, []byte, string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	endpoints := c.health.order(append([]string{c.endpoint}, c.failover...))
+	var lastErr error
+	for _, endpoint := range endpoints {
+		resp, body, err := c.post(ctx, endpoint, data)
+		if err == nil || !shouldFailover(err) {
+			c.health.record(endpoint, true)
+			return resp, body, endpoint, err
+		}
+		if ctx.Err() != nil {
+			return nil, nil, "", err
+		}
+		c.health.record(endpoint, false)
+		lastErr = err
+	}
+	if len(endpoints) > 1 {
+		lastErr = fmt.Errorf("all %d endpoints failed, last error: %w", len(endpoints), lastErr)
+	}
+	return nil, nil, "", lastErr
+}
@@ -0,0 +1,80 @@
+package pagerduty
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// unavailablePagerDuty makes the PagerDuty endpoints return a server error
+// for the duration of the test, the other requests being sent as usual.
+func unavailablePagerDuty(t *testing.T) {
+	transport := http.DefaultClient.Transport
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+	http.DefaultClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if isPagerDutyEndpoint(req.URL.String()) {
+			return &http.Response{
+				Status:     "503 Service Unavailable",
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       io.NopCloser(strings.NewReader(`{"status":"error","message":"unavailable"}`)),
+				Request:    req,
+			}, nil
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+}
+
+func TestEndpointHealth_order(t *testing.T) {
+	health := NewEndpointHealth(time.Minute)
+	health.record("a", false)
+	assert.Equal(t, []string{"b", "c", "a"}, health.order([]string{"a", "b", "c"}))
+	health.record("a", true)
+	assert.Equal(t, []string{"a", "b", "c"}, health.order([]string{"a", "b", "c"}))
+
+	health = NewEndpointHealth(0)
+	health.record("a", false)
+	assert.Equal(t, []string{"a", "b"}, health.order([]string{"a", "b"}))
+}
+
+func TestClient_failoverResponseParsing(t *testing.T) {
+	unavailablePagerDuty(t)
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer agent.Close()
+
+	// the responses of the agent are parsed leniently although the primary
+	// endpoint is PagerDuty
+	client := NewClient()
+	client.FailoverEndpoints(agent.URL)
+	event := &V2Event{RoutingKey: "token", Action: "trigger", DedupKey: "foo-bar"}
+	response, err := client.ManageEventWithContext(context.Background(), event)
+	require.NoError(t, err)
+	assert.Equal(t, agent.URL, response.Endpoint)
+	assert.Equal(t, "OK", response.Message)
+	assert.Equal(t, "foo-bar", response.DedupKey)
+
+	v1Response, err := client.ManageV1EventWithContext(context.Background(), NewV1Event(event))
+	require.NoError(t, err)
+	assert.Equal(t, agent.URL, v1Response.Endpoint)
+	assert.Equal(t, "OK", v1Response.Message)
+
+	// unless strict parsing is set for every endpoint
+	client.StrictResponses(true)
+	_, err = client.ManageEventWithContext(context.Background(), event)
+	assert.Error(t, err)
+}
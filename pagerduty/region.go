@@ -0,0 +1,25 @@
+package pagerduty
+
+// Region is a PagerDuty service region. Each region has its own events API
+// and REST API hosts.
+type Region string
+
+const (
+	RegionUS Region = "us"
+	RegionEU Region = "eu"
+)
+
+type regionHosts struct {
+	events string
+	rest   string
+}
+
+var regions = map[Region]regionHosts{
+	RegionUS: {events: "events.pagerduty.com", rest: "api.pagerduty.com"},
+	RegionEU: {events: "events.eu.pagerduty.com", rest: "api.eu.pagerduty.com"},
+}
+
+func (r Region) IsValid() bool {
+	_, ok := regions[r]
+	return ok
+}
@@ -0,0 +1,238 @@
+package pagerduty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PagerDuty utilities to access the PagerDuty REST API v2. Only the few
+// endpoints needed by the handler are covered.
+
+// restPageSize is the number of items requested per page of a list.
+const restPageSize = 100
+
+// restMaxRetries is the number of times a rate limited request is retried.
+const restMaxRetries = 3
+
+// restDefaultRetryAfter is how long to wait before retrying a rate limited
+// request when the response has no Retry-After header.
+const restDefaultRetryAfter = 5 * time.Second
+
+// Incident is a PagerDuty incident.
+type Incident struct {
+	ID          string `json:"id"`
+	IncidentKey string `json:"incident_key"`
+	Status      string `json:"status"`
+	Title       string `json:"title"`
+	HTMLURL     string `json:"html_url"`
+}
+
+// IncidentNote is a note added to the timeline of an incident.
+type IncidentNote struct {
+	ID      string `json:"id,omitempty"`
+	Content string `json:"content"`
+}
+
+// ListIncidentsOptions filters the incidents returned by ListIncidents.
+type ListIncidentsOptions struct {
+	IncidentKey string
+	Statuses    []string
+	// SortBy is the field the incidents are sorted by, optionally followed
+	// by :asc or :desc, e.g. created_at:desc.
+	SortBy string
+	// Limit is the maximum number of incidents returned, all of them when 0.
+	Limit int
+}
+
+// RESTAPIError represents the error response received when a REST API call
+// fails.
+type RESTAPIError struct {
+	// StatusCode is the HTTP response status code.
+	StatusCode int `json:"-"`
+
+	Code    int      `json:"code,omitempty"`
+	Message string   `json:"message,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+func (e RESTAPIError) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("HTTP response failed with status code %d", e.StatusCode)
+	}
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("HTTP response failed with status code %d, code: %d, message: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf(
+		"HTTP response failed with status code %d, code: %d, message: %s: %s",
+		e.StatusCode, e.Code, e.Message, apiErrorsDetailString(e.Errors),
+	)
+}
+
+type RESTClient struct {
+	endpoint string
+	token    string
+	from     string
+}
+
+// NewRESTClient returns a REST API client authenticating with the API token.
+func NewRESTClient(token string) *RESTClient {
+	return &RESTClient{endpoint: "https://" + regions[RegionUS].rest, token: token}
+}
+
+// Region sends the requests to the REST API of the service region. Unknown
+// regions are ignored.
+func (c *RESTClient) Region(region Region) {
+	if hosts, ok := regions[region]; ok {
+		c.endpoint = "https://" + hosts.rest
+	}
+}
+
+func (c *RESTClient) AlternateEndpoint(alternateEndpoint string) {
+	c.endpoint = strings.TrimSuffix(alternateEndpoint, "/")
+}
+
+// From sets the email address of the PagerDuty user the changes are made on
+// behalf of, required by account level API tokens.
+func (c *RESTClient) From(email string) {
+	c.from = email
+}
+
+// ListIncidents returns the incidents matching the options, following the
+// pagination until the limit is reached.
+func (c *RESTClient) ListIncidents(ctx context.Context, o ListIncidentsOptions) ([]Incident, error) {
+	query := url.Values{}
+	if len(o.IncidentKey) > 0 {
+		query.Set("incident_key", o.IncidentKey)
+	}
+	for _, status := range o.Statuses {
+		query.Add("statuses[]", status)
+	}
+	if len(o.SortBy) > 0 {
+		query.Set("sort_by", o.SortBy)
+	}
+	pageSize := restPageSize
+	if o.Limit > 0 && o.Limit < pageSize {
+		pageSize = o.Limit
+	}
+	query.Set("limit", strconv.Itoa(pageSize))
+
+	var incidents []Incident
+	for offset := 0; ; {
+		query.Set("offset", strconv.Itoa(offset))
+		var page struct {
+			Incidents []Incident `json:"incidents"`
+			More      bool       `json:"more"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/incidents?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, page.Incidents...)
+		if o.Limit > 0 && len(incidents) >= o.Limit {
+			return incidents[:o.Limit], nil
+		}
+		if !page.More || len(page.Incidents) == 0 {
+			return incidents, nil
+		}
+		offset += len(page.Incidents)
+	}
+}
+
+// CreateIncidentNote adds a note to the timeline of an incident.
+func (c *RESTClient) CreateIncidentNote(ctx context.Context, incidentID, content string) (*IncidentNote, error) {
+	request := struct {
+		Note IncidentNote `json:"note"`
+	}{Note: IncidentNote{Content: content}}
+	var response struct {
+		Note IncidentNote `json:"note"`
+	}
+	path := "/incidents/" + url.PathEscape(incidentID) + "/notes"
+	if err := c.do(ctx, http.MethodPost, path, request, &response); err != nil {
+		return nil, err
+	}
+	return &response.Note, nil
+}
+
+// do sends a request to the REST API and decodes the response into out.
+// Rate limited requests are retried after the delay given by the
+// Retry-After header.
+func (c *RESTClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var data []byte
+	if in != nil {
+		var err error
+		if data, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		req.Header.Set("User-Agent", UserAgent)
+		req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
+		req.Header.Set("Authorization", "Token token="+c.token)
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if len(c.from) > 0 {
+			req.Header.Set("From", c.from)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close() // explicitly discard error
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < restMaxRetries {
+			select {
+			case <-time.After(retryAfter(resp.Header.Get("Retry-After"))):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			var errResp struct {
+				Error RESTAPIError `json:"error"`
+			}
+			_ = json.Unmarshal(body, &errResp)
+			errResp.Error.StatusCode = resp.StatusCode
+			return errResp.Error
+		}
+
+		if out == nil || len(body) == 0 {
+			return nil
+		}
+		return json.Unmarshal(body, out)
+	}
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as
+// an HTTP date.
+func retryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return restDefaultRetryAfter
+}
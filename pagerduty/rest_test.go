@@ -0,0 +1,158 @@
+package pagerduty
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRESTClient_ListIncidents(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/incidents", r.URL.Path)
+		assert.Equal(t, "Token token=api-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/vnd.pagerduty+json;version=2", r.Header.Get("Accept"))
+		assert.Equal(t, UserAgent, r.Header.Get("User-Agent"))
+		query := r.URL.Query()
+		assert.Equal(t, "foo-bar", query.Get("incident_key"))
+		assert.Equal(t, []string{"triggered", "acknowledged"}, query["statuses[]"])
+		assert.Equal(t, "created_at:desc", query.Get("sort_by"))
+		requests = append(requests, query.Get("offset")+"/"+query.Get("limit"))
+
+		// three incidents, served in pages of the requested size
+		offset, _ := strconv.Atoi(query.Get("offset"))
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		var page struct {
+			Incidents []Incident `json:"incidents"`
+			More      bool       `json:"more"`
+		}
+		for i := offset; i < offset+limit && i < 3; i++ {
+			page.Incidents = append(page.Incidents, Incident{ID: fmt.Sprintf("P%d", i), IncidentKey: "foo-bar"})
+		}
+		page.More = offset+limit < 3
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	client := NewRESTClient("api-token")
+	client.AlternateEndpoint(server.URL + "/")
+	options := ListIncidentsOptions{
+		IncidentKey: "foo-bar",
+		Statuses:    []string{"triggered", "acknowledged"},
+		SortBy:      "created_at:desc",
+	}
+	incidents, err := client.ListIncidents(context.Background(), options)
+	require.NoError(t, err)
+	assert.Len(t, incidents, 3)
+	assert.Equal(t, []string{"0/100"}, requests)
+
+	// the pages are followed until the limit is reached
+	requests = nil
+	options.Limit = 1
+	incidents, err = client.ListIncidents(context.Background(), options)
+	require.NoError(t, err)
+	assert.Equal(t, []Incident{{ID: "P0", IncidentKey: "foo-bar"}}, incidents)
+	assert.Equal(t, []string{"0/1"}, requests)
+
+	requests = nil
+	options.Limit = 5
+	incidents, err = client.ListIncidents(context.Background(), options)
+	require.NoError(t, err)
+	assert.Len(t, incidents, 3)
+	assert.Equal(t, []string{"0/5"}, requests)
+}
+
+func TestRESTClient_ListIncidents_pagination(t *testing.T) {
+	var offsets []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset := r.URL.Query().Get("offset")
+		offsets = append(offsets, offset)
+		if offset == "0" {
+			_, _ = w.Write([]byte(`{"incidents":[{"id":"P0"},{"id":"P1"}],"more":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"incidents":[{"id":"P2"}],"more":false}`))
+	}))
+	defer server.Close()
+
+	client := NewRESTClient("api-token")
+	client.AlternateEndpoint(server.URL)
+	incidents, err := client.ListIncidents(context.Background(), ListIncidentsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []Incident{{ID: "P0"}, {ID: "P1"}, {ID: "P2"}}, incidents)
+	assert.Equal(t, []string{"0", "2"}, offsets)
+}
+
+func TestRESTClient_CreateIncidentNote(t *testing.T) {
+	rateLimited := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/incidents/PABC123/notes", r.URL.Path)
+		assert.Equal(t, "oncall@example.com", r.Header.Get("From"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		if !rateLimited {
+			rateLimited = true
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		var body struct {
+			Note IncidentNote `json:"note"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "still failing", body.Note.Content)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"note":{"id":"PNOTE1","content":"still failing"}}`))
+	}))
+	defer server.Close()
+
+	client := NewRESTClient("api-token")
+	client.AlternateEndpoint(server.URL)
+	client.From("oncall@example.com")
+	note, err := client.CreateIncidentNote(context.Background(), "PABC123", "still failing")
+	require.NoError(t, err)
+	assert.Equal(t, &IncidentNote{ID: "PNOTE1", Content: "still failing"}, note)
+	assert.True(t, rateLimited)
+}
+
+func TestRESTClient_error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"message":"Not Found","code":2100}}`))
+	}))
+	defer server.Close()
+
+	client := NewRESTClient("api-token")
+	client.AlternateEndpoint(server.URL)
+	_, err := client.CreateIncidentNote(context.Background(), "PABC123", "still failing")
+	var apiErr RESTAPIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, RESTAPIError{StatusCode: http.StatusNotFound, Code: 2100, Message: "Not Found"}, apiErr)
+	assert.EqualError(t, err, "HTTP response failed with status code 404, code: 2100, message: Not Found")
+}
+
+func TestRESTClient_Region(t *testing.T) {
+	client := NewRESTClient("api-token")
+	assert.Equal(t, "https://api.pagerduty.com", client.endpoint)
+	client.Region(RegionEU)
+	assert.Equal(t, "https://api.eu.pagerduty.com", client.endpoint)
+	client.Region("mars")
+	assert.Equal(t, "https://api.eu.pagerduty.com", client.endpoint)
+}
+
+func Test_retryAfter(t *testing.T) {
+	assert.Equal(t, 3*time.Second, retryAfter("3"))
+	assert.Equal(t, restDefaultRetryAfter, retryAfter(""))
+	assert.Equal(t, restDefaultRetryAfter, retryAfter("soon"))
+	assert.Equal(t, time.Duration(0), retryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)))
+	d := retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(t, d > 59*time.Minute && d <= time.Hour, d)
+}
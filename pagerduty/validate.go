@@ -0,0 +1,139 @@
+package pagerduty
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Constraints of the PagerDuty Common Event Format (PD-CEF) enforced by the
+// events API v2.
+const (
+	MaxSummaryLength  = 1024
+	MaxDedupKeyLength = 255
+	MaxLinks          = 50
+	MaxImages         = 50
+	MaxEventSize      = 512 * 1024
+)
+
+// severities are the accepted payload severities.
+var severities = []string{"critical", "error", "warning", "info"}
+
+// actions are the accepted event actions.
+var actions = []string{"trigger", "acknowledge", "resolve"}
+
+// timestampLayouts are the accepted ISO 8601 layouts of the payload
+// timestamp.
+var timestampLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999-0700"}
+
+// ValidationError is a constraint violated by a field of an event.
+type ValidationError struct {
+	// Field is the JSON path of the field, e.g. payload.summary.
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors are all the constraints violated by an event.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return "invalid event: " + strings.Join(messages, ", ")
+}
+
+// errOrNil returns nil when there is no validation error, so that the
+// result of Validate can be compared to nil.
+func (e ValidationErrors) errOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the event against the PD-CEF constraints, so that invalid
+// events are found before PagerDuty rejects them. The error is a
+// ValidationErrors listing every violated constraint.
+func (e *V2Event) Validate() error {
+	var errs ValidationErrors
+	if len(e.RoutingKey) == 0 {
+		errs = append(errs, ValidationError{Field: "routing_key", Message: "is required"})
+	}
+	if !contains(actions, e.Action) {
+		errs = append(errs, ValidationError{Field: "event_action", Message: fmt.Sprintf("invalid action %q", e.Action)})
+	}
+	if len(e.DedupKey) == 0 && e.Action != "trigger" {
+		errs = append(errs, ValidationError{Field: "dedup_key", Message: "is required to " + e.Action})
+	}
+	if utf8.RuneCountInString(e.DedupKey) > MaxDedupKeyLength {
+		errs = append(errs, ValidationError{Field: "dedup_key", Message: fmt.Sprintf("longer than %d characters", MaxDedupKeyLength)})
+	}
+	if len(e.Links) > MaxLinks {
+		errs = append(errs, ValidationError{Field: "links", Message: fmt.Sprintf("more than %d links", MaxLinks)})
+	}
+	if len(e.Images) > MaxImages {
+		errs = append(errs, ValidationError{Field: "images", Message: fmt.Sprintf("more than %d images", MaxImages)})
+	}
+
+	if e.Payload == nil {
+		if e.Action == "trigger" {
+			errs = append(errs, ValidationError{Field: "payload", Message: "is required to trigger"})
+		}
+	} else if err := e.Payload.Validate(); err != nil {
+		errs = append(errs, err.(ValidationErrors)...)
+	}
+
+	if data, err := json.Marshal(e); err != nil {
+		errs = append(errs, ValidationError{Field: "payload.custom_details", Message: err.Error()})
+	} else if len(data) > MaxEventSize {
+		errs = append(errs, ValidationError{Field: "event", Message: fmt.Sprintf("larger than %d bytes", MaxEventSize)})
+	}
+	return errs.errOrNil()
+}
+
+// Validate checks the payload against the PD-CEF constraints. The error is
+// a ValidationErrors listing every violated constraint.
+func (p *V2Payload) Validate() error {
+	var errs ValidationErrors
+	if len(p.Summary) == 0 {
+		errs = append(errs, ValidationError{Field: "payload.summary", Message: "is required"})
+	} else if utf8.RuneCountInString(p.Summary) > MaxSummaryLength {
+		errs = append(errs, ValidationError{Field: "payload.summary", Message: fmt.Sprintf("longer than %d characters", MaxSummaryLength)})
+	}
+	if len(p.Source) == 0 {
+		errs = append(errs, ValidationError{Field: "payload.source", Message: "is required"})
+	}
+	if !contains(severities, p.Severity) {
+		errs = append(errs, ValidationError{Field: "payload.severity", Message: fmt.Sprintf("invalid severity %q", p.Severity)})
+	}
+	if len(p.Timestamp) > 0 && !validTimestamp(p.Timestamp) {
+		errs = append(errs, ValidationError{Field: "payload.timestamp", Message: fmt.Sprintf("invalid ISO 8601 timestamp %q", p.Timestamp)})
+	}
+	return errs.errOrNil()
+}
+
+func validTimestamp(timestamp string) bool {
+	for _, layout := range timestampLayouts {
+		if _, err := time.Parse(layout, timestamp); err == nil {
+			return true
+		}
+	}
+	return false
+}
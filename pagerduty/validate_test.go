@@ -0,0 +1,93 @@
+package pagerduty
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validEvent() *V2Event {
+	return &V2Event{
+		RoutingKey: "token",
+		Action:     "trigger",
+		DedupKey:   "foo-bar",
+		Payload: &V2Payload{
+			Summary:   "foo/bar failed",
+			Source:    "foo",
+			Severity:  "critical",
+			Timestamp: "2024-01-02T03:04:05.000+0100",
+		},
+	}
+}
+
+func TestV2Event_Validate(t *testing.T) {
+	assert.NoError(t, validEvent().Validate())
+	assert.NoError(t, (&V2Event{RoutingKey: "token", Action: "resolve", DedupKey: "foo-bar"}).Validate())
+
+	event := validEvent()
+	event.RoutingKey = ""
+	event.Action = "close"
+	event.Links = make([]interface{}, MaxLinks+1)
+	event.Payload.Summary = strings.Repeat("a", MaxSummaryLength+1)
+	event.Payload.Source = ""
+	event.Payload.Severity = "sev1"
+	event.Payload.Timestamp = "yesterday"
+	err := event.Validate()
+	var errs ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	fields := make([]string, 0, len(errs))
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal(t, []string{
+		"routing_key", "event_action", "links", "payload.summary", "payload.source",
+		"payload.severity", "payload.timestamp",
+	}, fields)
+	assert.Contains(t, err.Error(), `invalid event: routing_key: is required, event_action: invalid action "close"`)
+
+	// a trigger requires a payload, with a summary
+	err = (&V2Event{RoutingKey: "token", Action: "trigger"}).Validate()
+	assert.EqualError(t, err, "invalid event: payload: is required to trigger")
+	err = (&V2Payload{Source: "foo", Severity: "info"}).Validate()
+	assert.EqualError(t, err, "invalid event: payload.summary: is required")
+
+	// acknowledges and resolves require a deduplication key
+	err = (&V2Event{RoutingKey: "token", Action: "resolve"}).Validate()
+	assert.EqualError(t, err, "invalid event: dedup_key: is required to resolve")
+}
+
+func TestV2Event_Validate_limits(t *testing.T) {
+	event := validEvent()
+	event.DedupKey = strings.Repeat("a", MaxDedupKeyLength+1)
+	event.Images = make([]interface{}, MaxImages+1)
+	assert.EqualError(t, event.Validate(),
+		"invalid event: dedup_key: longer than 255 characters, images: more than 50 images")
+
+	// the lengths are counted in characters, not bytes
+	event = validEvent()
+	event.DedupKey = strings.Repeat("é", MaxDedupKeyLength)
+	event.Payload.Summary = strings.Repeat("é", MaxSummaryLength)
+	assert.NoError(t, event.Validate())
+	event.Payload.Summary += "é"
+	assert.EqualError(t, event.Validate(), "invalid event: payload.summary: longer than 1024 characters")
+
+	event = validEvent()
+	event.Payload.Details = strings.Repeat("a", MaxEventSize)
+	assert.EqualError(t, event.Validate(), "invalid event: event: larger than 524288 bytes")
+
+	event = validEvent()
+	event.Payload.Details = map[string]interface{}{"invalid": func() {}}
+	var errs ValidationErrors
+	require.ErrorAs(t, event.Validate(), &errs)
+	assert.Equal(t, "payload.custom_details", errs[0].Field)
+}
+
+func Test_validTimestamp(t *testing.T) {
+	assert.True(t, validTimestamp("2024-01-02T03:04:05Z"))
+	assert.True(t, validTimestamp("2024-01-02T03:04:05.123+01:00"))
+	assert.True(t, validTimestamp("2024-01-02T03:04:05.000+0100"))
+	assert.False(t, validTimestamp("2024-01-02 03:04:05"))
+	assert.False(t, validTimestamp("1704164645"))
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+)
+
+// redactedMarker replaces the redacted values.
+const redactedMarker = "[REDACTED]"
+
+// envVarRegexp matches strings written as environment variables, such as the
+// check env_vars entries.
+var envVarRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=`)
+
+func parseRedactPatterns(value string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, item := range splitList(value) {
+		re, err := regexp.Compile(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern: %v", err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+func validateRedactKeys(value string) error {
+	for _, key := range splitList(value) {
+		if _, err := path.Match(key, ""); err != nil {
+			return fmt.Errorf("invalid redact key pattern %q", key)
+		}
+	}
+	return nil
+}
+
+// redactor replaces the sensitive values of a PagerDuty event.
+type redactor struct {
+	keys     []string
+	patterns []*regexp.Regexp
+}
+
+// newRedactor returns a redactor for the event. The keys to redact are the
+// ones in the entity Redact list, or the Sensu defaults when the list is
+// empty, and the ones given with --redact-keys.
+func newRedactor(event *corev2.Event) redactor {
+	keys := corev2.DefaultRedactFields
+	if event.Entity != nil && len(event.Entity.Redact) > 0 {
+		keys = event.Entity.Redact
+	}
+	keys = append(append([]string{}, keys...), splitList(config.redactKeys)...)
+	for i := range keys {
+		keys[i] = strings.ToLower(keys[i])
+	}
+	return redactor{keys: keys, patterns: config.redactRegexps}
+}
+
+// isSensitiveKey reports whether the key matches one of the keys to redact,
+// ignoring case.
+func (r redactor) isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range r.keys {
+		if matchPattern(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString replaces the matches of the redact patterns. When a pattern
+// has capture groups, only the groups are replaced. Strings written as
+// environment variables have their value replaced when the name is
+// sensitive.
+func (r redactor) redactString(s string) string {
+	if m := envVarRegexp.FindStringSubmatch(s); m != nil && r.isSensitiveKey(m[1]) {
+		return m[0] + redactedMarker
+	}
+	for _, re := range r.patterns {
+		s = replaceMatches(re, s)
+	}
+	return s
+}
+
+func replaceMatches(re *regexp.Regexp, s string) string {
+	if re.NumSubexp() == 0 {
+		return re.ReplaceAllLiteralString(s, redactedMarker)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range re.FindAllStringSubmatchIndex(s, -1) {
+		for i := 2; i < len(match); i += 2 {
+			start, end := match[i], match[i+1]
+			if start < last {
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(redactedMarker)
+			last = end
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// redactValue walks a decoded JSON value, replacing the values of sensitive
+// keys and redacting the strings.
+func (r redactor) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if r.isSensitiveKey(key) {
+				v[key] = redactedMarker
+			} else {
+				v[key] = r.redactValue(child)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.redactValue(child)
+		}
+		return v
+	case string:
+		return r.redactString(v)
+	}
+	return value
+}
+
+// redactEvent redacts every field of the PagerDuty event built from the
+// Sensu event before it is sent.
+func redactEvent(event *corev2.Event, pdEvent *pagerduty.V2Event) error {
+	r := newRedactor(event)
+	pdEvent.ClientURL = r.redactString(pdEvent.ClientURL)
+	for i, link := range pdEvent.Links {
+		decoded, err := decodeJSONValue(link)
+		if err != nil {
+			return fmt.Errorf("failed to redact links: %v", err)
+		}
+		pdEvent.Links[i] = r.redactValue(decoded)
+	}
+
+	// resolves may have no payload
+	payload := pdEvent.Payload
+	if payload == nil {
+		return nil
+	}
+	for _, field := range []*string{&payload.Summary, &payload.Source, &payload.Component, &payload.Group, &payload.Class} {
+		*field = r.redactString(*field)
+	}
+
+	if payload.Details != nil {
+		details, err := decodeJSONValue(payload.Details)
+		if err != nil {
+			return fmt.Errorf("failed to redact details: %v", err)
+		}
+		payload.Details = r.redactValue(details)
+	}
+	return nil
+}
+
+// decodeJSONValue converts a value to the generic representation of its JSON
+// encoding.
+func decodeJSONValue(value interface{}) (interface{}, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	err = json.Unmarshal(b, &decoded)
+	return decoded, err
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// Records that haven't been seen for this long are dropped from the state
+// file to keep it from growing forever.
+const stateRecordTTL = 7 * 24 * time.Hour
+
+// stateRecord is what the handler remembers about an incident, keyed by its
+// deduplication key.
+type stateRecord struct {
+	Action   string    `json:"action"`
+	Severity string    `json:"severity"`
+	Sent     time.Time `json:"sent"`
+	Seen     time.Time `json:"seen"`
+	Deferred bool      `json:"deferred,omitempty"`
+
+	// The event and the contact the incident was last seen for, to find
+	// the incidents of an entity.
+	Namespace string `json:"namespace,omitempty"`
+	Entity    string `json:"entity,omitempty"`
+	Check     string `json:"check,omitempty"`
+	Contact   string `json:"contact,omitempty"`
+	DedupKey  string `json:"dedup_key,omitempty"`
+
+	// Incident caches the PagerDuty incident last looked up through the REST
+	// API for the deduplication key.
+	Incident *cachedIncident `json:"incident,omitempty"`
+}
+
+// cachedIncident is the status of a PagerDuty incident when it was looked up.
+type cachedIncident struct {
+	ID      string    `json:"id,omitempty"`
+	Status  string    `json:"status,omitempty"`
+	Checked time.Time `json:"checked"`
+}
+
+// handlerState is the content of the state file shared by all the handler
+// executions.
+type handlerState struct {
+	Incidents map[string]stateRecord `json:"incidents"`
+	Storms    map[string]stormRecord `json:"storms,omitempty"`
+}
+
+func newHandlerState() *handlerState {
+	return &handlerState{Incidents: map[string]stateRecord{}, Storms: map[string]stormRecord{}}
+}
+
+// readState reads the state file without locking it. The state file is always
+// replaced atomically so a reader never observes a partial write.
+func readState(path string) (*handlerState, error) {
+	state := newHandlerState()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %v", err)
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %v", err)
+	}
+	if state.Incidents == nil {
+		state.Incidents = map[string]stateRecord{}
+	}
+	if state.Storms == nil {
+		state.Storms = map[string]stormRecord{}
+	}
+	return state, nil
+}
+
+// updateState applies fn to the latest state while holding the state file
+// lock, and writes the result back.
+func updateState(path string, fn func(*handlerState) error) error {
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := readState(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(state); err != nil {
+		return err
+	}
+	state.prune(time.Now())
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b, 0600)
+}
+
+func (s *handlerState) prune(now time.Time) {
+	for key, record := range s.Incidents {
+		if now.Sub(record.Seen) > stateRecordTTL {
+			delete(s.Incidents, key)
+		}
+	}
+	for key, storm := range s.Storms {
+		if now.Sub(storm.Seen) > stateRecordTTL {
+			delete(s.Storms, key)
+		}
+	}
+}
+
+func (r *stateRecord) setEvent(event *corev2.Event, contact, dedupKey string) {
+	r.Namespace = event.Namespace
+	r.Entity = event.Entity.Name
+	r.Check = event.Check.Name
+	r.Contact = contact
+	r.DedupKey = dedupKey
+}
+
+// stateKey identifies an incident in the state file. The same dedup key sent
+// to different contacts ends up in different PagerDuty services.
+func stateKey(contact, dedupKey string) string {
+	if len(contact) == 0 {
+		return dedupKey
+	}
+	return contact + ":" + dedupKey
+}
+
+// isRedundantTrigger reports whether a trigger repeats the previously sent
+// trigger within the resend window. Resolves and severity changes are never
+// redundant.
+func isRedundantTrigger(previous stateRecord, found bool, action, severity string, now time.Time, window time.Duration) bool {
+	if !found || window <= 0 || action != "trigger" || previous.Action != "trigger" {
+		return false
+	}
+	if previous.Severity != severity {
+		return false
+	}
+	return now.Sub(previous.Sent) < window
+}
+
+// isResolveNeeded reports whether a resolve may close an incident. When the
+// state file knows about the incident, a resolve is needed only if the last
+// action sent was a trigger. Otherwise the check history is consulted: the
+// resolve is needed when the execution before the current one wasn't OK, or
+// when there isn't enough history to tell.
+func isResolveNeeded(event *corev2.Event, previous stateRecord, found bool) bool {
+	if found {
+		return previous.Action == "trigger"
+	}
+
+	history := event.Check.History
+	if len(history) < 2 {
+		return true
+	}
+	return history[len(history)-2].Status != 0
+}
+
+// recordIncident remembers what was done for an incident. The action and
+// severity are only updated when the event was actually sent. Failing to
+// update the state never fails the handler as the event was already handled.
+func recordIncident(log *slog.Logger, event *corev2.Event, contact, dedupKey, action, severity string, sent bool) {
+	if len(config.stateFile) == 0 {
+		return
+	}
+	now := time.Now()
+	err := updateState(config.stateFile, func(state *handlerState) error {
+		key := stateKey(contact, dedupKey)
+		record := state.Incidents[key]
+		record.setEvent(event, contact, dedupKey)
+		if sent {
+			record.Action = action
+			record.Severity = severity
+			record.Sent = now
+			record.Deferred = false
+			record.Incident = nil
+		}
+		record.Seen = now
+		state.Incidents[key] = record
+		return nil
+	})
+	if err != nil {
+		log.Warn("failed to update state file", "error", err)
+	}
+}
+
+// deferIncident remembers that a trigger was held back by a maintenance
+// window, to be sent by the first event handled once the window is over.
+func deferIncident(log *slog.Logger, event *corev2.Event, contact, dedupKey string) {
+	err := updateState(config.stateFile, func(state *handlerState) error {
+		key := stateKey(contact, dedupKey)
+		record := state.Incidents[key]
+		record.setEvent(event, contact, dedupKey)
+		record.Deferred = true
+		record.Seen = time.Now()
+		state.Incidents[key] = record
+		return nil
+	})
+	if err != nil {
+		log.Warn("failed to update state file", "error", err)
+	}
+}
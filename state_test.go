@@ -0,0 +1,165 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_isRedundantTrigger(t *testing.T) {
+	now := time.Now()
+	sentTrigger := stateRecord{Action: "trigger", Severity: "warning", Sent: now.Add(-5 * time.Minute)}
+
+	tests := []struct {
+		name     string
+		previous stateRecord
+		found    bool
+		action   string
+		severity string
+		window   time.Duration
+		want     bool
+	}{
+		{"no previous record", stateRecord{}, false, "trigger", "warning", time.Hour, false},
+		{"window disabled", sentTrigger, true, "trigger", "warning", 0, false},
+		{"same trigger within window", sentTrigger, true, "trigger", "warning", time.Hour, true},
+		{"same trigger after window", sentTrigger, true, "trigger", "warning", time.Minute, false},
+		{"severity escalation", sentTrigger, true, "trigger", "critical", time.Hour, false},
+		{"severity downgrade", sentTrigger, true, "trigger", "info", time.Hour, false},
+		{"resolve", sentTrigger, true, "resolve", "info", time.Hour, false},
+		{"trigger after resolve", stateRecord{Action: "resolve", Severity: "warning", Sent: now}, true, "trigger", "warning", time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRedundantTrigger(tt.previous, tt.found, tt.action, tt.severity, now, tt.window))
+		})
+	}
+}
+
+func Test_updateState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := readState(path)
+	require.NoError(t, err)
+	assert.Empty(t, state.Incidents)
+
+	now := time.Now()
+	require.NoError(t, updateState(path, func(state *handlerState) error {
+		state.Incidents["fresh"] = stateRecord{Action: "trigger", Seen: now}
+		state.Incidents["stale"] = stateRecord{Action: "trigger", Seen: now.Add(-stateRecordTTL - time.Hour)}
+		return nil
+	}))
+
+	state, err = readState(path)
+	require.NoError(t, err)
+	assert.Contains(t, state.Incidents, "fresh")
+	assert.NotContains(t, state.Incidents, "stale")
+}
+
+func Test_manageIncident_resendWindow(t *testing.T) {
+	restoreConfig(t)
+	server, sent := newTestEndpoint(t)
+	config = testConfig(server.URL)
+	config.stateFile = filepath.Join(t.TempDir(), "state.json")
+	config.resendInterval = time.Hour
+
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Status = 1
+
+	result, err := manageIncident(event, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "success", result.Status)
+
+	result, err = manageIncident(event, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, skippedStatus, result.Status)
+	assert.Len(t, *sent, 1)
+
+	// escalations and resolves are always sent
+	event.Check.Status = 2
+	result, err = manageIncident(event, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "success", result.Status)
+
+	event.Check.Status = 0
+	result, err = manageIncident(event, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "success", result.Status)
+	assert.Len(t, *sent, 3)
+
+	// the same dedup key for another contact is tracked separately
+	event.Check.Status = 2
+	_, err = manageIncident(event, "token", "team_a")
+	require.NoError(t, err)
+	assert.Len(t, *sent, 4)
+}
+
+func Test_isResolveNeeded(t *testing.T) {
+	withHistory := func(statuses ...uint32) *corev2.Event {
+		event := corev2.FixtureEvent("foo", "bar")
+		event.Check.History = nil
+		for i, status := range statuses {
+			event.Check.History = append(event.Check.History, corev2.CheckHistory{Status: status, Executed: int64(i)})
+		}
+		return event
+	}
+
+	tests := []struct {
+		name     string
+		event    *corev2.Event
+		previous stateRecord
+		found    bool
+		want     bool
+	}{
+		{"previous execution failing", withHistory(0, 2, 0), stateRecord{}, false, true},
+		{"previous execution OK", withHistory(2, 0, 0), stateRecord{}, false, false},
+		{"not enough history", withHistory(0), stateRecord{}, false, true},
+		{"trigger recorded", withHistory(0, 0), stateRecord{Action: "trigger"}, true, true},
+		{"resolve recorded", withHistory(2, 0), stateRecord{Action: "resolve"}, true, false},
+		{"never sent", withHistory(2, 0), stateRecord{}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isResolveNeeded(tt.event, tt.previous, tt.found))
+		})
+	}
+}
+
+func Test_manageIncident_skipUnneededResolves(t *testing.T) {
+	restoreConfig(t)
+	server, sent := newTestEndpoint(t)
+	config = testConfig(server.URL)
+	config.skipUnneededResolves = true
+
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Status = 0
+	event.Check.History = []corev2.CheckHistory{{Status: 0}, {Status: 0}}
+
+	result, err := manageIncident(event, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, skippedStatus, result.Status)
+
+	event.Check.History = []corev2.CheckHistory{{Status: 1}, {Status: 0}}
+	result, err = manageIncident(event, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "success", result.Status)
+	assert.Equal(t, []string{"resolve foo-bar"}, sentActions(*sent))
+
+	// with a state file, the recorded trigger decides
+	config.stateFile = filepath.Join(t.TempDir(), "state.json")
+	event.Check.Status = 2
+	_, err = manageIncident(event, "token", "")
+	require.NoError(t, err)
+
+	event.Check.Status = 0
+	event.Check.History = []corev2.CheckHistory{{Status: 0}, {Status: 0}}
+	_, err = manageIncident(event, "token", "")
+	require.NoError(t, err)
+	result, err = manageIncident(event, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, skippedStatus, result.Status)
+	assert.Equal(t, []string{"resolve foo-bar", "trigger foo-bar", "resolve foo-bar"}, sentActions(*sent))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+)
+
+// stormRecord tracks the triggers of a storm group, keyed by deduplication
+// key. Once more than --storm-threshold incidents were triggered within
+// --storm-window, the storm is active and the triggers of the group are
+// folded into a single aggregate incident until all its members are resolved.
+type stormRecord struct {
+	Triggers map[string]time.Time   `json:"triggers,omitempty"`
+	Active   bool                   `json:"active,omitempty"`
+	Members  map[string]stormMember `json:"members,omitempty"`
+	Seen     time.Time              `json:"seen"`
+}
+
+// stormMember is an event folded into an aggregate incident, keyed by its
+// own deduplication key.
+type stormMember struct {
+	Entity   string `json:"entity"`
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+}
+
+// stormOutcome is what to do with an event folded into a storm.
+type stormOutcome struct {
+	// Send is false when the aggregate incident doesn't need to be updated.
+	Send    bool
+	Action  string
+	Members map[string]stormMember
+}
+
+// stormDedupKey is the deduplication key of the aggregate incident of a storm
+// group.
+func stormDedupKey(group string) string {
+	return "storm-" + group
+}
+
+// foldIntoStorm updates the storm of the event group and reports whether the
+// event is folded into the aggregate incident. Events whose own incident is
+// open aren't folded, so that it can still be resolved.
+func foldIntoStorm(key, dedupKey, action, severity string, event *corev2.Event, ownIncidentOpen bool) (stormOutcome, bool, error) {
+	var (
+		outcome stormOutcome
+		folded  bool
+	)
+	now := time.Now()
+	err := updateState(config.stateFile, func(state *handlerState) error {
+		storm := state.Storms[key]
+		storm.Seen = now
+		defer func() { state.Storms[key] = storm }()
+
+		_, isMember := storm.Members[dedupKey]
+		if action == "resolve" {
+			if !storm.Active || !isMember {
+				return nil
+			}
+			delete(storm.Members, dedupKey)
+			folded = true
+			if len(storm.Members) == 0 {
+				storm.Active = false
+				storm.Triggers = nil
+				outcome = stormOutcome{Send: true, Action: "resolve"}
+			}
+			return nil
+		}
+
+		if !storm.Active {
+			if ownIncidentOpen {
+				return nil
+			}
+			if storm.Triggers == nil {
+				storm.Triggers = map[string]time.Time{}
+			}
+			for k, t := range storm.Triggers {
+				if now.Sub(t) >= config.stormInterval {
+					delete(storm.Triggers, k)
+				}
+			}
+			storm.Triggers[dedupKey] = now
+			if uint64(len(storm.Triggers)) <= config.stormThreshold {
+				return nil
+			}
+			storm.Active = true
+			storm.Members = map[string]stormMember{}
+		} else if ownIncidentOpen && !isMember {
+			return nil
+		}
+
+		folded = true
+		member := stormMember{Entity: event.Entity.Name, Check: event.Check.Name, Severity: severity}
+		if previous, ok := storm.Members[dedupKey]; !ok || previous != member {
+			storm.Members[dedupKey] = member
+			outcome.Send = true
+		}
+		outcome.Action = "trigger"
+		outcome.Members = make(map[string]stormMember, len(storm.Members))
+		for k, v := range storm.Members {
+			outcome.Members[k] = v
+		}
+		return nil
+	})
+	return outcome, folded, err
+}
+
+// stormSeverity returns the most severe severity of the storm members.
+func stormSeverity(members map[string]stormMember) string {
+	severity := "info"
+	for _, member := range members {
+		if severityRanks[member.Severity] > severityRanks[severity] {
+			severity = member.Severity
+		}
+	}
+	return severity
+}
+
+// stormDetails lists the members of the storm, sorted by entity and check.
+func stormDetails(group string, members map[string]stormMember) map[string]interface{} {
+	affected := make([]string, 0, len(members))
+	for _, member := range members {
+		affected = append(affected, member.Entity+"/"+member.Check)
+	}
+	sort.Strings(affected)
+	return map[string]interface{}{
+		"storm_group": group,
+		"count":       len(members),
+		"members":     affected,
+	}
+}
+
+// handleStorm folds the event into the aggregate incident of its storm group
+// when a storm is active. It reports whether the event was folded, in which
+// case the event itself must not be sent.
+func handleStorm(ctx context.Context, log *slog.Logger, event *corev2.Event, token, contact, dedupKey, action, severity string, ownIncidentOpen bool, labels map[string]string) (incidentResult, bool, error) {
+	group, err := evalTemplate("stormGroup", config.stormGroupTemplate, event)
+	if err != nil {
+		return incidentResult{}, false, fmt.Errorf("failed to evaluate template %s: %v", config.stormGroupTemplate, err)
+	}
+
+	outcome, folded, err := foldIntoStorm(stateKey(contact, group), dedupKey, action, severity, event, ownIncidentOpen)
+	if err != nil {
+		log.Warn("failed to update storm state, sending the event", "error", err)
+		return incidentResult{}, false, nil
+	}
+	if !folded {
+		return incidentResult{}, false, nil
+	}
+
+	stormKey := stormDedupKey(group)
+	log = log.With("storm_group", group, "storm_dedup_key", stormKey)
+	if !outcome.Send {
+		log.Info("event folded into the storm incident")
+		return incidentResult{DedupKey: stormKey, Action: action, Status: skippedStatus}, true, nil
+	}
+
+	pdEvent := pagerduty.V2Event{
+		RoutingKey: token,
+		Action:     outcome.Action,
+		DedupKey:   stormKey,
+		Client:     config.clientName,
+	}
+	if outcome.Action == "trigger" {
+		pdEvent.Payload = &pagerduty.V2Payload{
+			Summary:  fmt.Sprintf("Storm of %d alerts in group %s", len(outcome.Members), group),
+			Source:   group,
+			Severity: stormSeverity(outcome.Members),
+			Group:    group,
+			Class:    "storm",
+			Details:  stormDetails(group, outcome.Members),
+		}
+	}
+
+	driver := newDriver()
+	response, err := submitEvent(ctx, log, driver, event, &pdEvent, labels)
+	if err != nil {
+		return incidentResult{}, true, err
+	}
+	log.Info(
+		"storm incident updated", "storm_action", outcome.Action, "members", len(outcome.Members),
+		"status", response.Status, "message", response.Message, "endpoint", response.Endpoint,
+	)
+	return incidentResult{DedupKey: stormKey, Action: outcome.Action, Status: response.Status}, true, nil
+}
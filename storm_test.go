@@ -0,0 +1,116 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_manageIncident_storm(t *testing.T) {
+	restoreConfig(t)
+	server, sent := newTestEndpoint(t)
+	config = testConfig(server.URL)
+	config.stateFile = filepath.Join(t.TempDir(), "state.json")
+	config.stormThreshold = 2
+	config.stormInterval = time.Minute
+	config.stormGroupTemplate = "{{.Check.Name}}"
+	config.skipUnneededResolves = true
+
+	failing := func(entity string) *corev2.Event {
+		event := corev2.FixtureEvent(entity, "disk")
+		event.Check.Status = 2
+		return event
+	}
+
+	// the state file knows c and d as resolved, the resolves of their folded
+	// triggers must still close the storm incident
+	for _, entity := range []string{"c", "d"} {
+		_, err := manageIncident(corev2.FixtureEvent(entity, "disk"), "token", "")
+		require.NoError(t, err)
+	}
+	*sent = nil
+
+	// triggers up to the threshold are sent as they are
+	for _, entity := range []string{"a", "b"} {
+		_, err := manageIncident(failing(entity), "token", "")
+		require.NoError(t, err)
+	}
+	// a repeated trigger of an open incident doesn't count
+	_, err := manageIncident(failing("a"), "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"trigger a-disk", "trigger b-disk", "trigger a-disk"}, sentActions(*sent))
+
+	// triggers above the threshold are folded into the storm incident
+	*sent = nil
+	for _, entity := range []string{"c", "d"} {
+		result, err := manageIncident(failing(entity), "token", "")
+		require.NoError(t, err)
+		assert.Equal(t, "storm-disk", result.DedupKey)
+	}
+	assert.Equal(t, []string{"trigger storm-disk", "trigger storm-disk"}, sentActions(*sent))
+	details := (*sent)[1]["payload"].(map[string]interface{})["custom_details"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"c/disk", "d/disk"}, details["members"])
+	assert.Equal(t, float64(2), details["count"])
+
+	// repeated triggers of members don't update the storm incident
+	*sent = nil
+	result, err := manageIncident(failing("c"), "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, skippedStatus, result.Status)
+	assert.Empty(t, *sent)
+
+	// incidents opened before the storm are resolved on their own
+	_, err = manageIncident(corev2.FixtureEvent("a", "disk"), "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"resolve a-disk"}, sentActions(*sent))
+
+	// the storm incident is resolved with its last member
+	*sent = nil
+	result, err = manageIncident(corev2.FixtureEvent("c", "disk"), "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, skippedStatus, result.Status)
+	assert.Empty(t, *sent)
+	result, err = manageIncident(corev2.FixtureEvent("d", "disk"), "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "storm-disk", result.DedupKey)
+	assert.Equal(t, []string{"resolve storm-disk"}, sentActions(*sent))
+
+	// the triggers are counted again once the storm is over
+	*sent = nil
+	_, err = manageIncident(failing("e"), "token", "")
+	require.NoError(t, err)
+	event := corev2.FixtureEvent("e", "cpu")
+	event.Check.Status = 2
+	_, err = manageIncident(event, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"trigger e-disk", "trigger e-cpu"}, sentActions(*sent))
+}
+
+func Test_manageIncident_stormTemplateError(t *testing.T) {
+	restoreConfig(t)
+	server, sent := newTestEndpoint(t)
+	config = testConfig(server.URL)
+	config.stateFile = filepath.Join(t.TempDir(), "state.json")
+	config.stormThreshold = 2
+	config.stormInterval = time.Minute
+	config.stormGroupTemplate = "{{.Check.Missing}}"
+
+	event := corev2.FixtureEvent("a", "disk")
+	event.Check.Status = 2
+	_, err := manageIncident(event, "token", "")
+	assert.ErrorContains(t, err, "failed to evaluate template {{.Check.Missing}}")
+	assert.Empty(t, *sent)
+}
+
+func Test_stormSeverity(t *testing.T) {
+	members := map[string]stormMember{
+		"a": {Entity: "a", Check: "disk", Severity: "warning"},
+		"b": {Entity: "b", Check: "disk", Severity: "critical"},
+	}
+	assert.Equal(t, "critical", stormSeverity(members))
+	assert.Equal(t, "info", stormSeverity(nil))
+}
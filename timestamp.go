@@ -0,0 +1,79 @@
+package main
+
+import (
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// timestampLayout is the RFC 3339 layout of the PD-CEF timestamp, with
+// millisecond precision.
+const timestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// timestampSource is where the PD-CEF timestamp of an event is taken from.
+type timestampSource string
+
+const (
+	eventTimestampSource    timestampSource = "event"
+	executedTimestampSource timestampSource = "executed"
+	issuedTimestampSource   timestampSource = "issued"
+	nowTimestampSource      timestampSource = "now"
+)
+
+func (s timestampSource) IsValid() bool {
+	switch s {
+	case eventTimestampSource, executedTimestampSource, issuedTimestampSource, nowTimestampSource:
+		return true
+	}
+	return false
+}
+
+// unixTime converts a Unix timestamp to a time. Sensu timestamps are in
+// seconds, but metric points and events created by other tools may use
+// milliseconds, microseconds or nanoseconds, the unit is guessed from the
+// magnitude.
+func unixTime(timestamp int64) time.Time {
+	switch {
+	case timestamp >= 1e17:
+		return time.Unix(0, timestamp)
+	case timestamp >= 1e14:
+		return time.UnixMicro(timestamp)
+	case timestamp >= 1e11:
+		return time.UnixMilli(timestamp)
+	}
+	return time.Unix(timestamp, 0)
+}
+
+// getTimestamp returns the PD-CEF timestamp of the event from the configured
+// source. The timestamp is only sent with --timestamp-source, or with
+// --use-event-timestamp for the event timestamp. Unset timestamps aren't sent,
+// PagerDuty then uses the time the event is received.
+func getTimestamp(event *corev2.Event) string {
+	source := timestampSource(config.timestampSource)
+	if len(source) == 0 {
+		if !config.useEventTimestamp {
+			return ""
+		}
+		source = eventTimestampSource
+	}
+
+	var timestamp int64
+	switch source {
+	case nowTimestampSource:
+		return time.Now().UTC().Format(timestampLayout)
+	case executedTimestampSource:
+		if event.Check != nil {
+			timestamp = event.Check.Executed
+		}
+	case issuedTimestampSource:
+		if event.Check != nil {
+			timestamp = event.Check.Issued
+		}
+	default:
+		timestamp = event.Timestamp
+	}
+	if timestamp <= 0 {
+		return ""
+	}
+	return unixTime(timestamp).UTC().Format(timestampLayout)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_unixTime(t *testing.T) {
+	expected := time.Unix(1700000000, 0)
+	assert.True(t, expected.Equal(unixTime(1700000000)))
+	assert.True(t, expected.Equal(unixTime(1700000000000)))
+	assert.True(t, expected.Equal(unixTime(1700000000000000)))
+	assert.True(t, expected.Equal(unixTime(1700000000000000000)))
+}
+
+func Test_getTimestamp_sources(t *testing.T) {
+	restoreConfig(t)
+
+	event := &corev2.Event{
+		Timestamp: 1561246706,
+		Check:     &corev2.Check{Executed: 1561246700, Issued: 1561246690},
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"event", "2019-06-22T23:38:26.000Z"},
+		{"executed", "2019-06-22T23:38:20.000Z"},
+		{"issued", "2019-06-22T23:38:10.000Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			config = HandlerConfig{timestampSource: tt.source}
+			assert.Equal(t, tt.want, getTimestamp(event))
+
+			// unset timestamps aren't sent
+			assert.Empty(t, getTimestamp(&corev2.Event{Check: &corev2.Check{}}))
+		})
+	}
+
+	// the now source doesn't depend on the event
+	config = HandlerConfig{timestampSource: "now"}
+	before := time.Now().Truncate(time.Millisecond)
+	got, err := time.Parse(time.RFC3339, getTimestamp(&corev2.Event{}))
+	require.NoError(t, err)
+	assert.False(t, got.Before(before))
+	assert.False(t, got.After(time.Now()))
+
+	// the executed and issued sources fall back to no timestamp without check
+	for _, source := range []string{"executed", "issued"} {
+		config = HandlerConfig{timestampSource: source}
+		assert.Empty(t, getTimestamp(&corev2.Event{Timestamp: 1561246706}), source)
+	}
+
+	// the source takes precedence over --use-event-timestamp
+	config = HandlerConfig{timestampSource: "issued", useEventTimestamp: true}
+	assert.Equal(t, "2019-06-22T23:38:10.000Z", getTimestamp(event))
+}
+
+func Test_manageIncident_timestampSource(t *testing.T) {
+	restoreConfig(t)
+
+	server, sent := newTestEndpoint(t)
+	timestamp := func() interface{} {
+		return (*sent)[len(*sent)-1]["payload"].(map[string]interface{})["timestamp"]
+	}
+
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Status = 2
+	event.Timestamp = 1561246706
+	event.Check.Executed = 1561246700000
+	event.Check.Issued = 1561246690
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		{"", nil},
+		{"event", "2019-06-22T23:38:26.000Z"},
+		{"executed", "2019-06-22T23:38:20.000Z"},
+		{"issued", "2019-06-22T23:38:10.000Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			config = testConfig(server.URL)
+			config.authToken = "token"
+			config.timestampSource = tt.source
+			require.NoError(t, checkArgs(event))
+			_, err := manageIncident(event, "token", "")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, timestamp())
+		})
+	}
+
+	config.timestampSource = "now"
+	require.NoError(t, checkArgs(event))
+	_, err := manageIncident(event, "token", "")
+	require.NoError(t, err)
+	_, err = time.Parse(time.RFC3339, timestamp().(string))
+	assert.NoError(t, err)
+
+	config.timestampSource = "received"
+	assert.EqualError(t, checkArgs(event), "invalid timestamp source: received")
+}
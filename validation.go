@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+)
+
+// invalidEventPolicy is what to do with events which violate the PagerDuty
+// event format constraints.
+type invalidEventPolicy string
+
+const (
+	fixInvalidEventPolicy  invalidEventPolicy = "fix"
+	failInvalidEventPolicy invalidEventPolicy = "fail"
+)
+
+func (p invalidEventPolicy) IsValid() bool {
+	switch p {
+	case fixInvalidEventPolicy, failInvalidEventPolicy:
+		return true
+	}
+	return false
+}
+
+// validateEvent returns the constraints violated by the event. The webhook
+// output doesn't require a routing key.
+func validateEvent(pdEvent *pagerduty.V2Event) (pagerduty.ValidationErrors, error) {
+	err := pdEvent.Validate()
+	var errs pagerduty.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil, err
+	}
+	if outputType(config.output) != webhookOutput {
+		return errs, nil
+	}
+	var filtered pagerduty.ValidationErrors
+	for _, e := range errs {
+		if e.Field != "routing_key" {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered, nil
+}
+
+// checkEvent validates the event before it is sent, so that invalid events
+// fail fast instead of being rejected by PagerDuty. With the fix policy, the
+// invalid fields which can be are truncated or normalized first.
+func checkEvent(log *slog.Logger, pdEvent *pagerduty.V2Event) error {
+	errs, err := validateEvent(pdEvent)
+	if err != nil || len(errs) == 0 {
+		return err
+	}
+	if invalidEventPolicy(config.invalidPolicy) == failInvalidEventPolicy {
+		return errs
+	}
+
+	fixEvent(log, pdEvent, errs)
+	if errs, err = validateEvent(pdEvent); err != nil || len(errs) == 0 {
+		return err
+	}
+	return errs
+}
+
+// fixEvent truncates or normalizes the invalid fields of the event.
+func fixEvent(log *slog.Logger, pdEvent *pagerduty.V2Event, errs pagerduty.ValidationErrors) {
+	p := pdEvent.Payload
+	for _, e := range errs {
+		fixed := true
+		switch e.Field {
+		case "payload.summary":
+			if len(p.Summary) == 0 {
+				p.Summary = pdEvent.DedupKey
+			} else {
+				// as many bytes are at most as many characters
+				p.Summary = truncateString(p.Summary, pagerduty.MaxSummaryLength)
+			}
+		case "payload.source":
+			p.Source = "unknown"
+		case "payload.severity":
+			// unknown severities, e.g. from a status map, are sent as errors
+			severity := strings.ToLower(strings.TrimSpace(p.Severity))
+			if _, ok := severityRanks[severity]; !ok {
+				severity = "error"
+			}
+			p.Severity = severity
+		case "payload.timestamp":
+			// PagerDuty uses the time the event is received instead
+			p.Timestamp = ""
+		case "links":
+			pdEvent.Links = pdEvent.Links[:pagerduty.MaxLinks]
+		case "images":
+			pdEvent.Images = pdEvent.Images[:pagerduty.MaxImages]
+		case "event":
+			if p == nil {
+				fixed = false
+				break
+			}
+			p.Details = fallbackDetails
+		default:
+			fixed = false
+		}
+		if fixed {
+			log.Warn("invalid event field fixed", "field", e.Field, "error", e.Message)
+		}
+	}
+}
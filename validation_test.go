@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validEvent() *pagerduty.V2Event {
+	return &pagerduty.V2Event{
+		RoutingKey: "token",
+		Action:     "trigger",
+		DedupKey:   "foo-bar",
+		Payload: &pagerduty.V2Payload{
+			Summary:   "foo/bar failed",
+			Source:    "foo",
+			Severity:  "critical",
+			Timestamp: "2024-01-02T03:04:05.000+0100",
+		},
+	}
+}
+
+func Test_checkEvent(t *testing.T) {
+	restoreConfig(t)
+
+	config = HandlerConfig{invalidPolicy: "fix"}
+	event := validEvent()
+	event.Links = make([]interface{}, pagerduty.MaxLinks+5)
+	event.Payload.Summary = strings.Repeat("a", pagerduty.MaxSummaryLength+10)
+	event.Payload.Source = ""
+	event.Payload.Severity = " CRITICAL"
+	event.Payload.Timestamp = "yesterday"
+	require.NoError(t, checkEvent(logger, event))
+	assert.Len(t, event.Links, pagerduty.MaxLinks)
+	assert.Len(t, event.Payload.Summary, pagerduty.MaxSummaryLength)
+	assert.Equal(t, "unknown", event.Payload.Source)
+	assert.Equal(t, "critical", event.Payload.Severity)
+	assert.Empty(t, event.Payload.Timestamp)
+
+	// summaries are truncated without splitting characters
+	event.Payload.Summary = strings.Repeat("é", pagerduty.MaxSummaryLength+10)
+	require.NoError(t, checkEvent(logger, event))
+	assert.True(t, utf8.ValidString(event.Payload.Summary))
+	assert.LessOrEqual(t, utf8.RuneCountInString(event.Payload.Summary), pagerduty.MaxSummaryLength)
+	assert.True(t, strings.HasPrefix(event.Payload.Summary, "éé"))
+
+	// unknown severities are sent as errors
+	event.Payload.Severity = "sev1"
+	require.NoError(t, checkEvent(logger, event))
+	assert.Equal(t, "error", event.Payload.Severity)
+
+	// the routing key can't be fixed
+	event.RoutingKey = ""
+	var errs pagerduty.ValidationErrors
+	require.ErrorAs(t, checkEvent(logger, event), &errs)
+	assert.Equal(t, "routing_key", errs[0].Field)
+
+	// but isn't required by the webhook output
+	config.output = "webhook"
+	assert.NoError(t, checkEvent(logger, event))
+
+	config = HandlerConfig{invalidPolicy: "fail"}
+	event = validEvent()
+	event.Payload.Severity = "CRITICAL"
+	assert.EqualError(t, checkEvent(logger, event), `invalid event: payload.severity: invalid severity "CRITICAL"`)
+	assert.Equal(t, "CRITICAL", event.Payload.Severity)
+}
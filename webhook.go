@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+	"github.com/sensu/sensu-plugin-sdk/templates"
+)
+
+// webhookDriver posts the events to a generic webhook, as PagerDuty v2 JSON
+// events or rendered with a body template.
+type webhookDriver struct {
+	url          string
+	auth         pagerduty.RequestAuth
+	bodyTemplate string
+	contentType  string
+}
+
+// webhookError is the error returned when the webhook doesn't accept an
+// event.
+type webhookError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e webhookError) Error() string {
+	return fmt.Sprintf("webhook response failed with status code %d, body: %s", e.StatusCode, e.Body)
+}
+
+func newWebhookDriver() webhookDriver {
+	return webhookDriver{
+		url:          config.webhookURL,
+		auth:         pagerduty.RequestAuth{Headers: config.webhookHeaders, HMACSecret: config.webhookSecret},
+		bodyTemplate: config.webhookTemplate,
+		contentType:  config.webhookContentType,
+	}
+}
+
+// bodyContentType returns the configured content type, or the content type
+// of the body, as templates may render bodies which aren't JSON.
+func (d webhookDriver) bodyContentType(body []byte) string {
+	if len(d.contentType) > 0 {
+		return d.contentType
+	}
+	if json.Valid(body) {
+		return "application/json"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// body returns the PagerDuty v2 JSON event, or the body template evaluated
+// with the event.
+func (d webhookDriver) body(event *pagerduty.V2Event) ([]byte, error) {
+	if len(d.bodyTemplate) == 0 {
+		return json.Marshal(event)
+	}
+	body, err := templates.EvalTemplate("webhookBody", d.bodyTemplate, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate template %s: %v", d.bodyTemplate, err)
+	}
+	return []byte(body), nil
+}
+
+// send posts the event to the webhook. As with lenient PagerDuty response
+// parsing, responses which aren't PagerDuty JSON responses are reported with
+// their HTTP status and body as the message.
+func (d webhookDriver) send(ctx context.Context, event *pagerduty.V2Event) (*pagerduty.V2EventResponse, error) {
+	body, err := d.body(event)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("User-Agent", pagerduty.UserAgent)
+	req.Header.Set("Content-Type", d.bodyContentType(body))
+	d.auth.Apply(req, body)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, webhookError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	var response pagerduty.V2EventResponse
+	if err := json.Unmarshal(respBody, &response); err != nil || len(response.Status) == 0 {
+		response = pagerduty.V2EventResponse{Status: resp.Status, DedupKey: event.DedupKey, Message: string(respBody)}
+	}
+	response.StatusCode = resp.StatusCode
+	response.Endpoint = d.url
+	return &response, nil
+}
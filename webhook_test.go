@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-pagerduty-handler/pagerduty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_manageIncident_webhook(t *testing.T) {
+	restoreConfig(t)
+
+	var (
+		request *http.Request
+		body    []byte
+		code    = http.StatusOK
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	config = HandlerConfig{
+		output:           "webhook",
+		webhookURL:       server.URL,
+		webhookHeaders:   map[string]string{"X-Team": "ops"},
+		webhookSecret:    "secret",
+		dedupKeyTemplate: "{{.Entity.Name}}-{{.Check.Name}}",
+		summaryTemplate:  "{{.Entity.Name}}/{{.Check.Name}} failed",
+		detailsFormat:    "string",
+	}
+
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Status = 2
+	result, err := manageIncident(event, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, incidentResult{DedupKey: "foo-bar", Action: "trigger", Status: "200 OK"}, result)
+	assert.Equal(t, "ops", request.Header.Get("X-Team"))
+	assert.Equal(t, pagerduty.Signature("secret", body), request.Header.Get(pagerduty.SignatureHeader))
+	assert.Equal(t, pagerduty.UserAgent, request.Header.Get("User-Agent"))
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+	var pdEvent map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &pdEvent))
+	assert.Equal(t, "trigger", pdEvent["event_action"])
+	assert.Equal(t, "foo-bar", pdEvent["dedup_key"])
+	assert.Equal(t, "critical", pdEvent["payload"].(map[string]interface{})["severity"])
+
+	// the body template is evaluated with the PagerDuty event
+	config.webhookTemplate = `{"key":"{{.DedupKey}}","title":{{toJSON .Payload.Summary}}}`
+	_, err = manageIncident(event, "", "")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"key":"foo-bar","title":"foo/bar failed"}`, string(body))
+	assert.Equal(t, pagerduty.Signature("secret", body), request.Header.Get(pagerduty.SignatureHeader))
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+
+	// bodies which aren't JSON aren't sent as JSON
+	config.webhookTemplate = `{{.Payload.Summary}} ({{.Payload.Severity}})`
+	_, err = manageIncident(event, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "foo/bar failed (critical)", string(body))
+	assert.Equal(t, "text/plain; charset=utf-8", request.Header.Get("Content-Type"))
+
+	config.webhookContentType = "application/x-www-form-urlencoded"
+	config.webhookTemplate = `key={{.DedupKey}}`
+	_, err = manageIncident(event, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-www-form-urlencoded", request.Header.Get("Content-Type"))
+
+	code = http.StatusUnauthorized
+	_, err = manageIncident(event, "", "")
+	assert.ErrorAs(t, err, &webhookError{})
+}
+
+func Test_processBatchLine_webhookAnnotations(t *testing.T) {
+	restoreConfig(t)
+	t.Setenv("WEBHOOK_KEY", "secret")
+
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-Webhook-Key"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	agentServer, agentSent := newTestEndpoint(t)
+
+	config = testConfig("")
+	config.Keyspace = "sensu.io/plugins/sensu-pagerduty-handler/config"
+	config.output = "webhook"
+	config.webhookURL = server.URL
+	config.webhookHeaders = map[string]string{"X-Webhook-Key": "webhook"}
+
+	// the webhook URL and its credentials can't be overridden by the agent
+	event := corev2.FixtureEvent("foo", "bar")
+	event.Check.Status = 2
+	event.Entity.Annotations = map[string]string{
+		config.Keyspace + "/webhook-url":         agentServer.URL,
+		config.Keyspace + "/webhook-headers":     `{"X-Webhook-Key":"env:WEBHOOK_KEY"}`,
+		config.Keyspace + "/webhook-hmac-secret": "agent",
+	}
+	line, err := json.Marshal(event)
+	require.NoError(t, err)
+	_, err = processBatchLine(line)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"webhook"}, keys)
+	assert.Empty(t, *agentSent)
+}